@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func aliasList(ctx *cmd.Context) error {
+	if len(config.Aliases) == 0 {
+		report.Infof("No aliases.")
+		return nil
+	}
+	var names []string
+	for name := range config.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, config.Aliases[name])
+	}
+	return w.Flush()
+}
+
+func aliasSet(ctx *cmd.Context) error {
+	name, definition := ctx.Args[0], ctx.Args[1]
+	if len(ctx.Args) > 2 {
+		var words []string
+		for _, w := range ctx.Args[1:] {
+			words = append(words, cmd.Quote(w))
+		}
+		definition = strings.Join(words, " ")
+	}
+	if _, err := cmd.Split(definition); err != nil {
+		return err
+	}
+	if c, _ := root.Find([]string{name}); c != root && c.Name == name {
+		report.Infof("note: alias %s hides the %s command", name, c.Path())
+	}
+	return updateConfig(configPath, func(c *Config) error {
+		if c.Aliases == nil {
+			c.Aliases = make(map[string]string)
+		}
+		c.Aliases[name] = definition
+		return nil
+	})
+}
+
+func aliasDelete(ctx *cmd.Context) error {
+	name := ctx.Args[0]
+	return updateConfig(configOrigin("aliases."+name), func(c *Config) error {
+		if _, ok := c.Aliases[name]; !ok {
+			return fmt.Errorf("no such alias: %s", name)
+		}
+		delete(c.Aliases, name)
+		return nil
+	})
+}
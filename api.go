@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/fancy"
+	"github.com/whub/faucet/sand"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func api(ctx *cmd.Context) error {
+	path := ctx.Args[0]
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	params := url.Values{}
+	for _, field := range ctx.Strings("field") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return &cmd.UsageError{Command: ctx.Node.Path(), Err: fmt.Errorf("field is not key=value: %s", field)}
+		}
+		params.Add(kv[0], kv[1])
+	}
+	// Only a method given explicitly vouches that the request is safe to
+	// send again.
+	method := strings.ToUpper(ctx.String("method"))
+	idempotent := method == "GET" || method == "HEAD"
+	if method == "" {
+		method = "GET"
+	}
+	spin := report.Step(method + " " + path)
+	body, err := sand.Raw(method, path, params, idempotent)
+	spin.Stop(err)
+	if err != nil {
+		return err
+	}
+	if filter := ctx.String("jq"); filter != "" {
+		return printFiltered(body, filter)
+	}
+	if fancy.IsTerminal(os.Stdout) {
+		var out bytes.Buffer
+		if json.Indent(&out, body, "", "  ") == nil {
+			body = out.Bytes()
+		}
+	}
+	os.Stdout.Write(bytes.TrimRight(body, "\n"))
+	fmt.Println()
+	return nil
+}
+
+// printFiltered prints the values that filter selects from the JSON in
+// body, one per line. A filter is a path such as .droplets[0].name, where
+// [] or .[] stands for every element. Strings are printed without quotes,
+// anything else as JSON.
+func printFiltered(body []byte, filter string) error {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	values, err := selectPath([]interface{}{v}, filterSteps(filter))
+	if err != nil {
+		return fmt.Errorf("--jq %s: %v", filter, err)
+	}
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			fmt.Println(s)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	}
+	return nil
+}
+
+// filterSteps splits .a[0].b[] into a, 0, b and [].
+func filterSteps(filter string) []string {
+	filter = strings.Replace(filter, "[", ".[", -1)
+	var steps []string
+	for _, s := range strings.Split(filter, ".") {
+		s = strings.TrimSuffix(s, "]")
+		switch {
+		case s == "":
+		case s == "[":
+			steps = append(steps, "[]")
+		default:
+			steps = append(steps, strings.TrimPrefix(s, "["))
+		}
+	}
+	return steps
+}
+
+func selectPath(values []interface{}, steps []string) ([]interface{}, error) {
+	for _, step := range steps {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if step == "[]" {
+					var keys []string
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else {
+					next = append(next, v[step])
+				}
+			case []interface{}:
+				if step == "[]" {
+					next = append(next, v...)
+					break
+				}
+				i, err := strconv.Atoi(step)
+				if err != nil {
+					return nil, fmt.Errorf("cannot index an array with %q", step)
+				}
+				if i < 0 {
+					i += len(v)
+				}
+				if i >= 0 && i < len(v) {
+					next = append(next, v[i])
+				} else {
+					next = append(next, nil)
+				}
+			case nil:
+				next = append(next, nil)
+			default:
+				return nil, fmt.Errorf("cannot select %q from %v", step, v)
+			}
+		}
+		values = next
+	}
+	return values, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterSteps(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{".", nil},
+		{".droplets", []string{"droplets"}},
+		{".droplets[0].name", []string{"droplets", "0", "name"}},
+		{".droplets[].name", []string{"droplets", "[]", "name"}},
+		{".droplets.[].name", []string{"droplets", "[]", "name"}},
+		{"[].id", []string{"[]", "id"}},
+		{".droplets[-1]", []string{"droplets", "-1"}},
+	}
+	for _, tt := range tests {
+		if got := filterSteps(tt.filter); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterSteps(%q) = %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestSelectPath(t *testing.T) {
+	const body = `{"droplets": [{"id": 1, "name": "web"}, {"id": 2, "name": "db"}], "meta": {"total": 2}}`
+	tests := []struct {
+		filter string
+		want   string
+		err    bool
+	}{
+		{".meta.total", `[2]`, false},
+		{".droplets[0].name", `["web"]`, false},
+		{".droplets[-1].id", `[2]`, false},
+		{".droplets[5].name", `[null]`, false},
+		{".droplets[].name", `["web","db"]`, false},
+		{".meta[]", `[2]`, false},
+		{".missing.deeper", `[null]`, false},
+		{".droplets.name", ``, true},
+		{".meta.total.x", ``, true},
+	}
+	for _, tt := range tests {
+		var v interface{}
+		dec := json.NewDecoder(strings.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		values, err := selectPath([]interface{}{v}, filterSteps(tt.filter))
+		if (err != nil) != tt.err {
+			t.Errorf("selectPath(%q) error = %v", tt.filter, err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		got, _ := json.Marshal(values)
+		if string(got) != tt.want {
+			t.Errorf("selectPath(%q) = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/fancy"
+	"github.com/whub/faucet/sand"
+	"io"
+	"os"
+	"strings"
+)
+
+// promptLine prints prompt and reads a line from stdin. With secret the
+// terminal does not echo what is typed.
+func promptLine(prompt string, secret bool) (string, error) {
+	report.Prompt(prompt)
+	if secret && fancy.IsTerminal(os.Stdin) {
+		state, err := stty("-g")
+		if err != nil {
+			return "", err
+		}
+		if _, err := stty("-echo"); err != nil {
+			return "", err
+		}
+		restore := onExit(func() { stty(state) })
+		defer func() {
+			restore()
+			report.Prompt("\n")
+		}()
+	}
+	// Read a byte at a time so nothing after the line is consumed.
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return strings.TrimSpace(string(line)), nil
+			}
+			line = append(line, b[0])
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			return strings.TrimSpace(string(line)), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func authLogin(ctx *cmd.Context) error {
+	clientId, err := promptLine("client id: ", false)
+	if err != nil {
+		return err
+	}
+	apiKey, err := promptLine("api key: ", true)
+	if err != nil {
+		return err
+	}
+	if clientId == "" || apiKey == "" {
+		return errors.New("client id and api key are both required")
+	}
+	sand.ClientId, sand.ApiKey = clientId, apiKey
+	spin := report.Step("checking credentials")
+	_, err = sand.GetRegions()
+	spin.Stop(err)
+	if err != nil {
+		return err
+	}
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	err = updateConfig(path, func(c *Config) error {
+		c.ClientId, c.ApiKey = clientId, apiKey
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	report.Infof("wrote credentials to %s", path)
+	if origin := configOrigin("apiKey"); origin != path && config.ApiKey != "" {
+		report.Infof("note: %s sets apiKey too and takes precedence", origin)
+	}
+	return nil
+}
+
+func authStatus(ctx *cmd.Context) error {
+	key, err := sand.ResolveApiKey()
+	if err != nil {
+		return err
+	}
+	if activeProfile != "" {
+		fmt.Printf("profile:    %s\n", activeProfile)
+	}
+	fmt.Printf("client id:  %s (%s)\n", sand.ClientId, clientIdFrom)
+	fmt.Printf("api key:    %s (%s)\n", maskSecret(key), apiKeyFrom)
+	spin := report.Step("checking credentials")
+	_, err = sand.GetRegions()
+	spin.Stop(err)
+	return err
+}
+
+func credentialOrigin(key string) string {
+	if origin, ok := configOrigins[key]; ok {
+		return origin
+	}
+	return "not set"
+}
+
+func authLogout(ctx *cmd.Context) error {
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("not logged in: %s does not exist", path)
+	}
+	err = updateConfig(path, func(c *Config) error {
+		if c.ClientId == "" && c.ApiKey == "" {
+			return fmt.Errorf("not logged in: no credentials in %s", path)
+		}
+		c.ClientId, c.ApiKey = "", ""
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	report.Infof("removed credentials from %s", path)
+	for _, key := range []string{"clientId", "apiKey"} {
+		if origin := configOrigins[key]; origin != "" && origin != path {
+			report.Infof("note: %s still sets %s", origin, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type batchLine struct {
+	number int
+	text   string
+	words  []string
+	result string
+}
+
+func batch(ctx *cmd.Context) error {
+	lines, err := readBatch(ctx.Args[0])
+	if err != nil {
+		return err
+	}
+	global := ctx.GlobalArgs()
+	failed, stopped := 0, false
+	for _, l := range lines {
+		if stopped {
+			l.result = "skipped"
+			continue
+		}
+		words := l.words
+		if ctx.Bool("wait") {
+			if node, rest := root.Find(words); node.Fn != nil && node.Has("wait") {
+				path := words[:len(words)-len(rest)]
+				words = append(append(append([]string{}, path...), "--wait"), rest...)
+			}
+		}
+		report.Infof("%d: %s", l.number, l.text)
+		args := append(append([]string{root.Name}, global...), words...)
+		if err := root.Dispatch(args, 1); err != nil {
+			printError(err)
+			l.result = "failed: " + err.Error()
+			failed++
+			stopped = !ctx.Bool("keep-going")
+			continue
+		}
+		l.result = "ok"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tCOMMAND\tRESULT")
+	for _, l := range lines {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", l.number, l.text, l.result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d commands failed", failed, len(lines))
+	}
+	return nil
+}
+
+// readBatch reads the commands in path, or stdin for "-", skipping blank
+// lines and # comments. Every line is split up front so that a quoting
+// mistake is reported before anything runs.
+func readBatch(path string) ([]*batchLine, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var lines []*batchLine
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		words, err := cmd.Split(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, number, err)
+		}
+		if words[0] == root.Name {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+		lines = append(lines, &batchLine{number: number, text: text, words: words})
+	}
+	return lines, scanner.Err()
+}
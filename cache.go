@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// Listings are cached on disk so completion does not have to wait on the
+// API every time tab is pressed. Every fresh listing refreshes the cache.
+const cacheTTL = 5 * time.Minute
+
+type cacheFile struct {
+	Time  time.Time       `json:"time"`
+	Items json.RawMessage `json:"items"`
+}
+
+// memoryCache holds what this process has read or written, so a long
+// running shell does not go back to disk either.
+var memoryCache = make(map[string]cacheFile)
+
+// warmReferenceData makes regions, sizes and images, which rarely change,
+// come from the cache instead of being fetched for every command. The
+// shell turns it on.
+var warmReferenceData bool
+
+func cachePath(name string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "faucet", name+".json"), nil
+}
+
+// cacheName keeps the listings of each profile's account apart.
+func cacheName(name string) string {
+	if activeProfile == "" {
+		return name
+	}
+	return filepath.Join("profiles", activeProfile, name)
+}
+
+func readCache(name string, v interface{}) bool {
+	name = cacheName(name)
+	if f, ok := memoryCache[name]; ok && time.Since(f.Time) <= cacheTTL {
+		return json.Unmarshal(f.Items, v) == nil
+	}
+	path, err := cachePath(name)
+	if err != nil {
+		return false
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var f cacheFile
+	if json.Unmarshal(b, &f) != nil || time.Since(f.Time) > cacheTTL {
+		return false
+	}
+	memoryCache[name] = f
+	return json.Unmarshal(f.Items, v) == nil
+}
+
+func writeCache(name string, v interface{}) {
+	name = cacheName(name)
+	items, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	f := cacheFile{time.Now(), items}
+	memoryCache[name] = f
+	path, err := cachePath(name)
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0700) != nil {
+		return
+	}
+	ioutil.WriteFile(path, b, 0600)
+}
+
+// cached fills v, a pointer to a listing, from the cache under name, or
+// failing that with what fetch returns, which is cached in turn.
+func cached(name string, v interface{}, fetch func() (interface{}, error)) error {
+	if readCache(name, v) {
+		return nil
+	}
+	return refresh(name, v, fetch)
+}
+
+// refresh fills v with what fetch returns and caches it under name.
+func refresh(name string, v interface{}, fetch func() (interface{}, error)) error {
+	items, err := fetch()
+	if err != nil {
+		return err
+	}
+	writeCache(name, items)
+	reflect.ValueOf(v).Elem().Set(reflect.ValueOf(items))
+	return nil
+}
+
+// referenceData fills v like cached when warmReferenceData is on, and
+// like refresh otherwise.
+func referenceData(name string, v interface{}, fetch func() (interface{}, error)) error {
+	if warmReferenceData {
+		return cached(name, v, fetch)
+	}
+	return refresh(name, v, fetch)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Split breaks a command line into words the way a shell would, honouring
+// single quotes, double quotes and backslash escapes.
+func Split(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quote, escaped := false, rune(0), false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// Quote returns s in a form Split reads back as a single word.
+func Quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$") {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// ExpandAlias splits an alias definition and substitutes args into it: $1
+// to $9 stand for single arguments and $@ for all of them. Unless the
+// definition uses $@, the args after the highest $N it uses are appended.
+func ExpandAlias(definition string, args []string) ([]string, error) {
+	words, err := Split(definition)
+	if err != nil {
+		return nil, err
+	}
+	var expanded []string
+	used, all := 0, false
+	for _, w := range words {
+		if w == "$@" {
+			expanded = append(expanded, args...)
+			all = true
+			continue
+		}
+		var b strings.Builder
+		for i := 0; i < len(w); i++ {
+			if w[i] == '$' && i+1 < len(w) && (w[i+1] == '@' || w[i+1] >= '1' && w[i+1] <= '9') {
+				i++
+				if w[i] == '@' {
+					b.WriteString(strings.Join(args, " "))
+					all = true
+					continue
+				}
+				n, _ := strconv.Atoi(w[i : i+1])
+				if n > len(args) {
+					return nil, errors.New("alias needs argument $" + w[i:i+1])
+				}
+				if n > used {
+					used = n
+				}
+				b.WriteString(args[n-1])
+				continue
+			}
+			b.WriteByte(w[i])
+		}
+		expanded = append(expanded, b.String())
+	}
+	if !all {
+		expanded = append(expanded, args[used:]...)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line  string
+		words []string
+		err   bool
+	}{
+		{"", nil, false},
+		{"  droplets   list ", []string{"droplets", "list"}, false},
+		{`rename 1 "new name"`, []string{"rename", "1", "new name"}, false},
+		{`rename 1 'it''s'`, []string{"rename", "1", "its"}, false},
+		{`echo 'a "b" c'`, []string{"echo", `a "b" c`}, false},
+		{`echo "a 'b' c"`, []string{"echo", "a 'b' c"}, false},
+		{`echo a\ b \"`, []string{"echo", "a b", `"`}, false},
+		{`echo 'a\b'`, []string{"echo", `a\b`}, false},
+		{`echo "" x`, []string{"echo", "", "x"}, false},
+		{`echo "open`, nil, true},
+		{`echo trailing\`, nil, true},
+	}
+	for _, tt := range tests {
+		words, err := Split(tt.line)
+		if (err != nil) != tt.err {
+			t.Errorf("Split(%q) error = %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(words, tt.words) {
+			t.Errorf("Split(%q) = %q, want %q", tt.line, words, tt.words)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, s := range []string{"plain", "", "two words", "it's", `say "hi"`, `back\slash`, "$1", "tab\there"} {
+		words, err := Split("x " + Quote(s))
+		if err != nil {
+			t.Errorf("Split(Quote(%q)): %v", s, err)
+			continue
+		}
+		if len(words) != 2 || words[1] != s {
+			t.Errorf("Split(Quote(%q)) = %q", s, words)
+		}
+	}
+	if got := Quote("plain"); got != "plain" {
+		t.Errorf("Quote(plain) = %s, want it unquoted", got)
+	}
+}
+
+func TestExpandAlias(t *testing.T) {
+	tests := []struct {
+		definition string
+		args       []string
+		want       []string
+		err        bool
+	}{
+		{"droplets list", nil, []string{"droplets", "list"}, false},
+		{"droplets list", []string{"-q"}, []string{"droplets", "list", "-q"}, false},
+		{"droplets show $1 --output=json", []string{"7"}, []string{"droplets", "show", "7", "--output=json"}, false},
+		{"droplets rename $2 $1", []string{"web", "7"}, []string{"droplets", "rename", "7", "web"}, false},
+		{"droplets tag web $@", []string{"1", "2"}, []string{"droplets", "tag", "web", "1", "2"}, false},
+		{"droplets tag web $@", nil, []string{"droplets", "tag", "web"}, false},
+		{`echo "id=$1"`, []string{"7"}, []string{"echo", "id=7"}, false},
+		{`echo "all: $@"`, []string{"a", "b"}, []string{"echo", "all: a b"}, false},
+		{`echo '$1'`, []string{"7"}, []string{"echo", "7"}, false},
+		{"droplets show $1", []string{"7", "-o", "json"}, []string{"droplets", "show", "7", "-o", "json"}, false},
+		{"droplets rename $2 $1", []string{"web", "7", "-q"}, []string{"droplets", "rename", "7", "web", "-q"}, false},
+		{`echo "all: $@" $1`, []string{"a", "b"}, []string{"echo", "all: a b", "a"}, false},
+		{"droplets show $2", []string{"7"}, nil, true},
+		{`droplets "show`, nil, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ExpandAlias(tt.definition, tt.args)
+		if (err != nil) != tt.err {
+			t.Errorf("ExpandAlias(%q, %q) error = %v", tt.definition, tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandAlias(%q, %q) = %q, want %q", tt.definition, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDispatchAlias(t *testing.T) {
+	var got []string
+	var profile string
+	root := Root("faucet").String("profile", "p", "", "profile to use")
+	root.Parent("droplets", "manage droplets").
+		Command("list", "list droplets", "[<tag>]", func(ctx *Context) error {
+			got, profile = ctx.Args, ctx.String("profile")
+			return nil
+		}).
+		String("output", "o", "", "output format")
+	root.UserAliases = map[string]string{
+		"prod": "--profile prod droplets list",
+		"dl":   "droplets list $1",
+		"loop": "pool",
+		"pool": "loop",
+	}
+	tests := []struct {
+		args    []string
+		want    []string
+		profile string
+		err     bool
+	}{
+		{[]string{"prod"}, nil, "prod", false},
+		{[]string{"-p", "dev", "prod", "web"}, []string{"web"}, "prod", false},
+		{[]string{"dl", "web", "-o", "json"}, []string{"web"}, "", false},
+		{[]string{"loop"}, nil, "", true},
+	}
+	for _, tt := range tests {
+		got, profile = nil, ""
+		err := root.Dispatch(append([]string{"faucet"}, tt.args...), 1)
+		if (err != nil) != tt.err {
+			t.Errorf("Dispatch(%q) error = %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || profile != tt.profile {
+			t.Errorf("Dispatch(%q) ran with %q and profile %q, want %q and %q", tt.args, got, profile, tt.want, tt.profile)
+		}
+	}
+	// An alias may wrap the command it hides.
+	root.UserAliases = map[string]string{"droplets": "--profile wrapped droplets"}
+	if err := root.Dispatch([]string{"faucet", "droplets", "list"}, 1); err != nil || profile != "wrapped" {
+		t.Errorf("Dispatch(droplets list) ran with profile %q, error = %v", profile, err)
+	}
+}
@@ -5,23 +5,70 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"text/tabwriter"
 )
 
-type CmdFunc func([]string) error
+type CmdFunc func(*Context) error
 
 type Node struct {
 	Name        string
 	Description string
+	Aliases     []string
 	Usage       string
+	Long        string
+	Examples    []string
+	Flags       []*Flag
+	Args        []Arg
+	Hidden      bool
+	Destructive bool
+	UserAliases map[string]string
+	PluginEnv   func(*Context) ([]string, error)
 	Fn          CmdFunc
 	Children    []*Node
+	parent      *Node
+	completers  map[string]Completer
+	middleware  []Middleware
 }
 
 var (
-	ErrInvalidArgs = errors.New("invalid args")
+	ErrInvalidArgs    = errors.New("invalid args")
+	ErrMissingCommand = errors.New("missing command")
 )
 
+// UsageError is returned by Dispatch when the command line does not match
+// the tree. The relevant usage has already been printed to stderr.
+type UsageError struct {
+	Command string
+	Err     error
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Command, e.Err)
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownCommandError is returned by Dispatch when a name does not match
+// any child of a parent node.
+type UnknownCommandError struct {
+	Command     string
+	Name        string
+	Ambiguous   bool
+	Suggestions []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	if e.Ambiguous {
+		return fmt.Sprintf("%s: ambiguous command: %s (could be %s)", e.Command, e.Name, strings.Join(e.Suggestions, ", "))
+	}
+	msg := fmt.Sprintf("%s: unknown command: %s", e.Command, e.Name)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf(" (did you mean `%s %s`?)", e.Command, strings.Join(e.Suggestions, "` or `"+e.Command+" "))
+	}
+	return msg
+}
+
 func Root(name string) *Node {
 	return &Node{
 		Name: name,
@@ -32,6 +79,8 @@ func (n *Node) Parent(name, description string) *Node {
 	child := &Node{
 		Name:        name,
 		Description: description,
+		Aliases:     builtinAliases[name],
+		parent:      n,
 	}
 	n.Children = append(n.Children, child)
 	return child
@@ -41,52 +90,99 @@ func (n *Node) Command(name, description, usage string, fn CmdFunc) *Node {
 	child := &Node{
 		Name:        name,
 		Description: description,
+		Aliases:     builtinAliases[name],
 		Usage:       usage,
+		Args:        parseArgs(usage),
 		Fn:          fn,
+		parent:      n,
 	}
 	n.Children = append(n.Children, child)
 	return child
 }
 
+// Path returns the names from the root down to n.
+func (n *Node) Path() string {
+	if n.parent == nil {
+		return n.Name
+	}
+	return n.parent.Path() + " " + n.Name
+}
+
 func (n *Node) Dispatch(args []string, index int) error {
+	matchedCmd := n.Path()
+	n.resetFlags()
 	if n.Fn == nil {
-		// Parent.
-		if len(args[index:]) == 0 {
-			printParentHelp(strings.Join(args[:index], " "), n)
-			return nil
-		}
-		name := args[index]
-		for _, c := range n.Children {
-			if c.Name == name {
-				return c.Dispatch(args, index+1)
-			}
+		return n.dispatchParent(args, index, nil)
+	}
+	for _, arg := range args[index:] {
+		if arg == "--" {
+			break
 		}
-		fmt.Printf("invalid command: %s\n\n", name)
-		printParentHelp(strings.Join(args[:index], " "), n)
-		return nil
-	} else {
-		// Command.
-		err := n.Fn(args[index:])
-		if err == ErrInvalidArgs {
-			printCommandHelp(strings.Join(args[:index], " "), n)
+		if isHelpFlag(arg) {
+			printHelp(os.Stdout, n)
 			return nil
 		}
-		return err
 	}
+	positional, err := n.parse(args[index:])
+	if err == nil {
+		err = n.checkArgs(positional)
+	}
+	if err != nil {
+		printCommandHelp(os.Stderr, n)
+		return &UsageError{matchedCmd, err}
+	}
+	err = n.wrap(n.Fn)(&Context{Node: n, Args: positional})
+	if err == ErrInvalidArgs {
+		printCommandHelp(os.Stderr, n)
+		return &UsageError{matchedCmd, err}
+	}
+	return err
 }
 
-func printParentHelp(matchedCmd string, n *Node) {
-	fmt.Printf("usage: %s <command> [<args>]\n\n", matchedCmd)
-	fmt.Println("commands:\n")
-	w := new(tabwriter.Writer)
-	w.Init(os.Stdout, 0, 8, 0, '\t', 0)
-	for _, child := range n.Children {
-		fmt.Fprintln(w, " ", child.Name, "\t", child.Description)
-	}
-	w.Flush()
-	fmt.Println()
-}
-
-func printCommandHelp(matchedCmd string, n *Node) {
-	fmt.Printf("usage: %s %s\n", matchedCmd, n.Usage)
+// dispatchParent parses n's flags from args[index:] and dispatches to the
+// child named by the first word left. A user alias there is expanded and
+// the result parsed again, so an alias may start with flags. expanded
+// holds the aliases already expanded on the way: like a shell, an alias is
+// not expanded inside itself, so one may wrap the command it hides.
+func (n *Node) dispatchParent(args []string, index int, expanded map[string]bool) error {
+	matchedCmd := n.Path()
+	rest, err := n.parse(args[index:])
+	if err != nil {
+		printParentHelp(os.Stderr, n)
+		return &UsageError{matchedCmd, err}
+	}
+	args = append(args[:index:index], rest...)
+	if len(args[index:]) > 0 && isHelpFlag(args[index]) {
+		printHelp(os.Stdout, n)
+		return nil
+	}
+	if len(args[index:]) == 0 {
+		printParentHelp(os.Stderr, n)
+		return &UsageError{matchedCmd, ErrMissingCommand}
+	}
+	name := args[index]
+	if definition, ok := n.UserAliases[name]; ok && !expanded[name] {
+		words, err := ExpandAlias(definition, args[index+1:])
+		if err != nil {
+			return &UsageError{matchedCmd + " " + name, err}
+		}
+		if len(words) == 0 {
+			return &UsageError{matchedCmd + " " + name, ErrMissingCommand}
+		}
+		if expanded == nil {
+			expanded = make(map[string]bool)
+		}
+		expanded[name] = true
+		return n.dispatchParent(append(args[:index:index], words...), index, expanded)
+	}
+	if c := n.child(name); c != nil {
+		return c.Dispatch(args, index+1)
+	}
+	if path := n.plugin(name); path != "" {
+		return n.runPlugin(path, args[index+1:])
+	}
+	if expanded[name] {
+		return &UsageError{matchedCmd + " " + name, errors.New("alias expands to itself")}
+	}
+	return n.unknownCommand(name)
 }
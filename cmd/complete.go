@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Completer returns the candidates for a positional argument, given the
+// positionals before it. A candidate may carry a description after a tab.
+type Completer func(args []string) []string
+
+// CompleteArg registers fn for every argument called name in n and the
+// nodes below it.
+func (n *Node) CompleteArg(name string, fn Completer) {
+	if n.completers == nil {
+		n.completers = make(map[string]Completer)
+	}
+	n.completers[name] = fn
+}
+
+func (n *Node) completer(name string) Completer {
+	for node := n; node != nil; node = node.parent {
+		if fn, ok := node.completers[name]; ok {
+			return fn
+		}
+	}
+	return nil
+}
+
+// Find follows args down the tree as far as they name children and returns
+// the node reached and the arguments left over. Persistent flags and their
+// values may come between the names, as they may for Dispatch.
+func (n *Node) Find(args []string) (*Node, []string) {
+	node := n
+	for len(args) > 0 && node.Fn == nil {
+		if isFlag(args[0]) {
+			name := strings.TrimLeft(args[0], "-")
+			eq := strings.Index(name, "=")
+			if eq >= 0 {
+				name = name[:eq]
+			}
+			f := node.lookupFlag(name)
+			if f == nil {
+				break
+			}
+			skip := 1
+			if f.Kind != "bool" && eq < 0 {
+				if len(args) == 1 {
+					break
+				}
+				skip = 2
+			}
+			args = args[skip:]
+			continue
+		}
+		child := node.child(args[0])
+		if child == nil {
+			break
+		}
+		node, args = child, args[1:]
+	}
+	return node, args
+}
+
+// Complete returns the candidates for the last of words, the arguments
+// after the root name. The last word is the one being typed and may be
+// empty.
+func (n *Node) Complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	prefix := words[len(words)-1]
+	node, rest := n.Find(words[:len(words)-1])
+	var candidates []string
+	switch {
+	case strings.HasPrefix(prefix, "-"):
+		for _, f := range append(node.inheritedFlags(), node.Flags...) {
+			candidates = append(candidates, "--"+f.Name+"\t"+f.Usage)
+		}
+	case node.Fn == nil:
+		if len(rest) == 0 {
+			candidates = node.childCandidates()
+		}
+	default:
+		positional, wantsValue := node.positionals(rest)
+		if wantsValue {
+			break
+		}
+		arg := node.argAt(len(positional))
+		if arg == nil {
+			break
+		}
+		if fn := node.completer(arg.Name); fn != nil {
+			candidates = fn(positional)
+		}
+	}
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// CompleteCommand is a Completer for arguments that name a command below
+// n, such as those of help.
+func (n *Node) CompleteCommand(args []string) []string {
+	node, rest := n.Find(args)
+	if node.Fn != nil || len(rest) > 0 {
+		return nil
+	}
+	return node.childCandidates()
+}
+
+func (n *Node) childCandidates() []string {
+	var candidates []string
+	for _, c := range n.Children {
+		if !c.Hidden {
+			candidates = append(candidates, c.Name+"\t"+c.Description)
+		}
+	}
+	return candidates
+}
+
+// positionals drops flags and their values from args. It also reports
+// whether the last argument is a flag still waiting for its value.
+func (n *Node) positionals(args []string) ([]string, bool) {
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(positional, args[i+1:]...), false
+		}
+		if !isFlag(arg) {
+			positional = append(positional, arg)
+			continue
+		}
+		f := n.lookupFlag(strings.TrimLeft(arg, "-"))
+		if f != nil && f.Kind != "bool" && !strings.Contains(arg, "=") {
+			if i+1 == len(args) {
+				return positional, true
+			}
+			i++
+		}
+	}
+	return positional, false
+}
+
+func (n *Node) argAt(i int) *Arg {
+	if i < len(n.Args) {
+		return &n.Args[i]
+	}
+	if len(n.Args) > 0 && n.Args[len(n.Args)-1].Variadic {
+		return &n.Args[len(n.Args)-1]
+	}
+	return nil
+}
+
+// The generated scripts ask the program itself for candidates through a
+// hidden command, so they never go stale as the tree changes.
+
+func GenBashCompletion(w io.Writer, root *Node, completeCmd string) {
+	fmt.Fprintf(w, `# bash completion for %[1]s
+_%[1]s() {
+	local IFS=$'\n'
+	COMPREPLY=($(%[1]s %[2]s -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null | cut -f1))
+}
+complete -o default -F _%[1]s %[1]s
+`, root.Name, completeCmd)
+}
+
+func GenZshCompletion(w io.Writer, root *Node, completeCmd string) {
+	fmt.Fprintf(w, `#compdef %[1]s
+_%[1]s() {
+	local -a candidates
+	local line
+	for line in "${(@f)$(%[1]s %[2]s -- "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+		[[ -z $line ]] && continue
+		if [[ $line == *$'\t'* ]]; then
+			candidates+=("${${line%%%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+		else
+			candidates+=("${line//:/\\:}")
+		fi
+	done
+	_describe '%[1]s' candidates
+}
+compdef _%[1]s %[1]s
+`, root.Name, completeCmd)
+}
+
+func GenFishCompletion(w io.Writer, root *Node, completeCmd string) {
+	fmt.Fprintf(w, `# fish completion for %[1]s
+function __%[1]s_complete
+	set -l words (commandline -opc) (commandline -ct)
+	%[1]s %[2]s -- $words[2..-1] 2>/dev/null
+end
+complete -c %[1]s -f -a '(__%[1]s_complete)'
+`, root.Name, completeCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	root, _ := testTree()
+	tests := []struct {
+		args []string
+		node string
+		rest []string
+	}{
+		{nil, "faucet", nil},
+		{[]string{"droplets"}, "faucet droplets", []string{}},
+		{[]string{"dr", "show", "1"}, "faucet droplets show", []string{"1"}},
+		{[]string{"--profile", "x", "dr"}, "faucet droplets", []string{}},
+		{[]string{"--profile=x", "-q", "droplets", "show"}, "faucet droplets show", []string{}},
+		{[]string{"droplets", "-p", "x", "show", "-n", "2", "1"}, "faucet droplets show", []string{"-n", "2", "1"}},
+		{[]string{"--profile"}, "faucet", []string{"--profile"}},
+		{[]string{"--nope", "droplets"}, "faucet", []string{"--nope", "droplets"}},
+		{[]string{"droplets", "nope"}, "faucet droplets", []string{"nope"}},
+	}
+	for _, tt := range tests {
+		node, rest := root.Find(tt.args)
+		if node.Path() != tt.node || len(rest) != len(tt.rest) || len(rest) > 0 && !reflect.DeepEqual(rest, tt.rest) {
+			t.Errorf("Find(%q) = %s, %q, want %s, %q", tt.args, node.Path(), rest, tt.node, tt.rest)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	root, show := testTree()
+	show.CompleteArg("id", func([]string) []string { return []string{"1", "2"} })
+	show.CompleteArg("field", func(args []string) []string { return []string{"name\t" + args[0]} })
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"dr"}, []string{"droplets\tmanage droplets"}},
+		{[]string{"--profile", "x", "dr"}, []string{"droplets\tmanage droplets"}},
+		{[]string{"-q", "droplets", "show", ""}, []string{"1", "2"}},
+		{[]string{"droplets", "show", "-n", "3", "1", ""}, []string{"name\t1"}},
+		{[]string{"droplets", "show", "-n", ""}, nil},
+		{[]string{"droplets", "show", "1", "name", ""}, nil},
+		{[]string{"--profile", ""}, nil},
+		{[]string{"--q"}, []string{"--quiet\tsay less"}},
+		{[]string{"droplets", "show", "--c"}, []string{"--count\thow many"}},
+	}
+	for _, tt := range tests {
+		if got := root.Complete(tt.words); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// Context is what a CmdFunc is called with: the node being run, its
+// positional arguments and the values of every flag it accepts.
+type Context struct {
+	Node *Node
+	Args []string
+}
+
+func (c *Context) value(name string) interface{} {
+	f := c.Node.lookupFlag(name)
+	if f == nil {
+		panic("cmd: no flag named " + name + " on " + c.Node.Path())
+	}
+	return f.get()
+}
+
+func (c *Context) String(name string) string {
+	return c.value(name).(string)
+}
+
+func (c *Context) Int(name string) int {
+	return c.value(name).(int)
+}
+
+func (c *Context) Bool(name string) bool {
+	return c.value(name).(bool)
+}
+
+func (c *Context) Duration(name string) time.Duration {
+	return c.value(name).(time.Duration)
+}
+
+func (c *Context) Strings(name string) []string {
+	return c.value(name).([]string)
+}
+
+// Has reports whether n or one of its ancestors declares the flag name.
+func (n *Node) Has(name string) bool {
+	return n.lookupFlag(name) != nil
+}
+
+// GlobalArgs returns the persistent flags inherited by the node that were
+// changed from their defaults, as arguments. Commands that dispatch other
+// commands, such as a shell, pass them on so the settings carry over.
+func (c *Context) GlobalArgs() []string {
+	var args []string
+	for _, f := range c.Node.inheritedFlags() {
+		switch v := f.get().(type) {
+		case []string:
+			for _, s := range v {
+				args = append(args, "--"+f.Name+"="+s)
+			}
+		default:
+			if s := fmt.Sprint(v); s != f.Default {
+				args = append(args, "--"+f.Name+"="+s)
+			}
+		}
+	}
+	return args
+}
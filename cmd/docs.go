@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Walk calls fn for n and every visible node below it, parents first.
+func (n *Node) Walk(fn func(*Node)) {
+	fn(n)
+	for _, c := range n.Children {
+		if !c.Hidden {
+			c.Walk(fn)
+		}
+	}
+}
+
+func (n *Node) pageName() string {
+	return strings.Replace(n.Path(), " ", "-", -1)
+}
+
+// GenMarkdown writes a single markdown reference for every command below
+// root.
+func GenMarkdown(w io.Writer, root *Node) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# %s command reference\n\n", root.Name)
+	root.Walk(func(n *Node) {
+		if n != root {
+			fmt.Fprintf(b, "## %s\n\n", n.Path())
+		}
+		if n.Description != "" {
+			fmt.Fprintf(b, "%s\n\n", n.Description)
+		}
+		fmt.Fprintf(b, "```\n%s\n```\n\n", n.UsageLine())
+		if n.Long != "" {
+			fmt.Fprintf(b, "%s\n\n", strings.TrimSpace(n.Long))
+		}
+		if len(n.Aliases) > 0 {
+			fmt.Fprintf(b, "Aliases: `%s`\n\n", strings.Join(n.Aliases, "`, `"))
+		}
+		if len(n.Flags) > 0 {
+			fmt.Fprint(b, "| Flag | Description |\n| --- | --- |\n")
+			for _, f := range n.Flags {
+				name := "`" + flagSynopsis(f) + "`"
+				if f.Short != "" {
+					name = "`-" + f.Short + "`, " + name
+				}
+				fmt.Fprintf(b, "| %s | %s |\n", name, strings.Replace(flagUsage(f), "|", `\|`, -1))
+			}
+			fmt.Fprintln(b)
+		}
+		if n.Fn == nil {
+			fmt.Fprint(b, "Commands:\n\n")
+			for _, c := range n.Children {
+				if !c.Hidden {
+					fmt.Fprintf(b, "- [%s](#%s) - %s\n", c.Name, anchor(c.Path()), c.Description)
+				}
+			}
+			fmt.Fprintln(b)
+		}
+		if len(n.Examples) > 0 {
+			fmt.Fprintf(b, "Examples:\n\n```\n%s\n```\n\n", strings.Join(n.Examples, "\n"))
+		}
+	})
+	return b.Flush()
+}
+
+func anchor(s string) string {
+	return strings.Replace(strings.ToLower(s), " ", "-", -1)
+}
+
+// GenManPages writes a roff man page to dir for root and every command
+// below it, named after the command path, e.g. faucet-droplets-list.1.
+func GenManPages(dir string, root *Node) error {
+	var err error
+	root.Walk(func(n *Node) {
+		if err != nil {
+			return
+		}
+		var f *os.File
+		f, err = os.Create(filepath.Join(dir, n.pageName()+".1"))
+		if err != nil {
+			return
+		}
+		err = genManPage(f, n)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+func genManPage(w io.Writer, n *Node) error {
+	b := bufio.NewWriter(w)
+	root := n.root()
+	fmt.Fprintf(b, ".TH %s 1 \"\" \"%s\" \"%s manual\"\n", strings.ToUpper(n.pageName()), root.Name, root.Name)
+	fmt.Fprintf(b, ".SH NAME\n%s", roff(n.pageName()))
+	if n.Description != "" {
+		fmt.Fprintf(b, " \\- %s", roff(n.Description))
+	}
+	fmt.Fprintf(b, "\n.SH SYNOPSIS\n.B %s\n%s\n", roff(n.Path()), roff(strings.TrimSpace(strings.TrimPrefix(n.UsageLine(), n.Path()))))
+	if n.Long != "" {
+		fmt.Fprintf(b, ".SH DESCRIPTION\n%s\n", roffText(strings.TrimSpace(n.Long)))
+	}
+	if len(n.Aliases) > 0 {
+		fmt.Fprintf(b, ".SH ALIASES\n%s\n", roff(strings.Join(n.Aliases, ", ")))
+	}
+	if len(n.Flags) > 0 {
+		fmt.Fprint(b, ".SH OPTIONS\n")
+		for _, f := range n.Flags {
+			fmt.Fprint(b, ".TP\n")
+			if f.Short != "" {
+				fmt.Fprintf(b, "\\fB%s\\fR, ", roff("-"+f.Short))
+			}
+			fmt.Fprintf(b, "\\fB%s\\fR\n%s\n", roff(flagSynopsis(f)), roff(flagUsage(f)))
+		}
+	}
+	if n.Fn == nil {
+		fmt.Fprint(b, ".SH COMMANDS\n")
+		for _, c := range n.Children {
+			if !c.Hidden {
+				fmt.Fprintf(b, ".TP\n\\fB%s\\fR(1)\n%s\n", roff(c.pageName()), roff(c.Description))
+			}
+		}
+	}
+	if len(n.Examples) > 0 {
+		fmt.Fprint(b, ".SH EXAMPLES\n.nf\n")
+		for _, e := range n.Examples {
+			fmt.Fprintln(b, roff(e))
+		}
+		fmt.Fprint(b, ".fi\n")
+	}
+	if n.parent != nil {
+		fmt.Fprintf(b, ".SH SEE ALSO\n\\fB%s\\fR(1)\n", roff(n.parent.pageName()))
+	}
+	return b.Flush()
+}
+
+// roffText escapes a block of help text for a man page. Runs of indented
+// lines, such as tables, are set without filling so they keep their
+// layout.
+func roffText(s string) string {
+	var lines []string
+	literal := false
+	for _, l := range strings.Split(roff(s), "\n") {
+		indented := strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")
+		if indented && !literal {
+			lines = append(lines, ".nf")
+			literal = true
+		} else if !indented && l != "" && literal {
+			lines = append(lines, ".fi")
+			literal = false
+		}
+		lines = append(lines, l)
+	}
+	if literal {
+		lines = append(lines, ".fi")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roff escapes text for use in a man page.
+func roff(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
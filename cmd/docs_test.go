@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoffText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain text\nwrapped", "plain text\nwrapped"},
+		{"Codes:\n\n  0  ok\n  1  error\n\nMore.", "Codes:\n\n.nf\n  0  ok\n  1  error\n\n.fi\nMore."},
+		{"Run:\n\tfaucet -q", "Run:\n.nf\n\tfaucet \\-q\n.fi"},
+		{".dot first", "\\&.dot first"},
+	}
+	for _, tt := range tests {
+		if got := roffText(tt.text); got != tt.want {
+			t.Errorf("roffText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestGenManPageKeepsTables(t *testing.T) {
+	_, show := testTree()
+	show.Long = "Exit codes:\n\n  0  success\n  2  usage error\n"
+	var b bytes.Buffer
+	if err := genManPage(&b, show); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), ".SH DESCRIPTION\nExit codes:\n\n.nf\n  0  success\n  2  usage error\n.fi\n") {
+		t.Errorf("man page does not keep the table unfilled:\n%s", b.String())
+	}
+}
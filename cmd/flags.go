@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flag is an option declared on a node. Bool flags take no value; every
+// other kind takes the next argument or the part after "=". Flags declared
+// on a parent node are persistent: they are accepted anywhere on the
+// command line of every command below it.
+type Flag struct {
+	Name    string
+	Short   string
+	Usage   string
+	Kind    string
+	Default string
+	set     func(string) error
+	get     func() interface{}
+	reset   func()
+}
+
+// Arg is a named positional argument, parsed from the node's usage string:
+// <name> is required, [<name>] is optional and a trailing ... repeats.
+type Arg struct {
+	Name     string
+	Optional bool
+	Variadic bool
+}
+
+func (n *Node) flag(f *Flag) *Node {
+	n.Flags = append(n.Flags, f)
+	return n
+}
+
+func (n *Node) String(name, short, value, usage string) *Node {
+	v := value
+	return n.flag(&Flag{
+		Name: name, Short: short, Usage: usage, Kind: "string", Default: value,
+		set:   func(s string) error { v = s; return nil },
+		get:   func() interface{} { return v },
+		reset: func() { v = value },
+	})
+}
+
+func (n *Node) Int(name, short string, value int, usage string) *Node {
+	v := value
+	return n.flag(&Flag{
+		Name: name, Short: short, Usage: usage, Kind: "int", Default: strconv.Itoa(value),
+		set: func(s string) error {
+			i, err := strconv.Atoi(s)
+			v = i
+			return err
+		},
+		get:   func() interface{} { return v },
+		reset: func() { v = value },
+	})
+}
+
+func (n *Node) Bool(name, short string, value bool, usage string) *Node {
+	v := value
+	return n.flag(&Flag{
+		Name: name, Short: short, Usage: usage, Kind: "bool", Default: strconv.FormatBool(value),
+		set: func(s string) error {
+			b, err := strconv.ParseBool(s)
+			v = b
+			return err
+		},
+		get:   func() interface{} { return v },
+		reset: func() { v = value },
+	})
+}
+
+func (n *Node) Duration(name, short string, value time.Duration, usage string) *Node {
+	v := value
+	return n.flag(&Flag{
+		Name: name, Short: short, Usage: usage, Kind: "duration", Default: value.String(),
+		set: func(s string) error {
+			d, err := time.ParseDuration(s)
+			v = d
+			return err
+		},
+		get:   func() interface{} { return v },
+		reset: func() { v = value },
+	})
+}
+
+// Strings declares a flag that may be given more than once; each use adds
+// to the list.
+func (n *Node) Strings(name, short, usage string) *Node {
+	var v []string
+	return n.flag(&Flag{
+		Name: name, Short: short, Usage: usage, Kind: "string...",
+		set:   func(s string) error { v = append(v, s); return nil },
+		get:   func() interface{} { return v },
+		reset: func() { v = nil },
+	})
+}
+
+// lookupFlag finds a flag declared on n or, persistently, on one of its
+// ancestors.
+func (n *Node) lookupFlag(name string) *Flag {
+	for node := n; node != nil; node = node.parent {
+		for _, f := range node.Flags {
+			if f.Name == name || (f.Short != "" && f.Short == name) {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+func (n *Node) resetFlags() {
+	for _, f := range n.Flags {
+		f.reset()
+	}
+}
+
+// inheritedFlags returns the persistent flags n accepts from its ancestors.
+func (n *Node) inheritedFlags() []*Flag {
+	var flags []*Flag
+	for node := n.parent; node != nil; node = node.parent {
+		flags = append(append([]*Flag{}, node.Flags...), flags...)
+	}
+	return flags
+}
+
+func parseArgs(usage string) []Arg {
+	var args []Arg
+	for _, field := range splitUsage(usage) {
+		arg := Arg{}
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			arg.Optional = true
+			field = field[1 : len(field)-1]
+		}
+		if strings.HasSuffix(field, "...") {
+			arg.Variadic = true
+			field = strings.TrimSuffix(field, "...")
+		}
+		arg.Name = strings.Trim(field, "<>")
+		args = append(args, arg)
+	}
+	return args
+}
+
+// splitUsage splits a usage string on the spaces between arguments, keeping
+// the spaces inside <...> names.
+func splitUsage(usage string) []string {
+	var fields []string
+	depth, start := 0, -1
+	for i, r := range usage {
+		switch {
+		case r == '<':
+			depth++
+		case r == '>':
+			depth--
+		case r == ' ' && depth == 0:
+			if start >= 0 {
+				fields = append(fields, usage[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, usage[start:])
+	}
+	return fields
+}
+
+// parse sets the flags of n and its ancestors from args and returns the
+// positional arguments. On a parent node it stops at the first positional,
+// which names the child, and returns it with everything after it.
+func (n *Node) parse(args []string) ([]string, error) {
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !isFlag(arg) || (n.Fn == nil && isHelpFlag(arg)) {
+			if n.Fn == nil {
+				return append(positional, args[i:]...), nil
+			}
+			positional = append(positional, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value, hasValue = name[:eq], name[eq+1:], true
+		}
+		f := n.lookupFlag(name)
+		if f == nil {
+			return nil, fmt.Errorf("unknown flag: %s", arg)
+		}
+		if !hasValue {
+			if f.Kind == "bool" {
+				value = "true"
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			} else {
+				return nil, fmt.Errorf("flag needs a value: %s", arg)
+			}
+		}
+		if err := f.set(value); err != nil {
+			return nil, fmt.Errorf("invalid value %q for flag %s", value, arg)
+		}
+	}
+	return positional, nil
+}
+
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && !isNumber(arg)
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func (n *Node) checkArgs(positional []string) error {
+	required, variadic := 0, false
+	for _, a := range n.Args {
+		if !a.Optional {
+			required++
+		}
+		variadic = variadic || a.Variadic
+	}
+	if len(positional) < required {
+		return fmt.Errorf("missing <%s>", n.Args[len(positional)].Name)
+	}
+	if len(positional) > len(n.Args) && !variadic {
+		return errors.New("too many arguments")
+	}
+	return nil
+}
+
+// UsageLine returns the synopsis of n generated from its flags and args.
+func (n *Node) UsageLine() string {
+	if n.Fn == nil {
+		if len(n.Flags) > 0 || len(n.inheritedFlags()) > 0 {
+			return n.Path() + " [<global flags>] <command> [<args>]"
+		}
+		return n.Path() + " <command> [<args>]"
+	}
+	parts := []string{n.Path()}
+	for _, f := range n.Flags {
+		parts = append(parts, "["+flagSynopsis(f)+"]")
+	}
+	if len(n.inheritedFlags()) > 0 {
+		parts = append(parts, "[<global flags>]")
+	}
+	for _, a := range n.Args {
+		s := "<" + a.Name + ">"
+		if a.Variadic {
+			s += "..."
+		}
+		if a.Optional {
+			s = "[" + s + "]"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func flagSynopsis(f *Flag) string {
+	s := "--" + f.Name
+	switch f.Kind {
+	case "bool":
+	case "string...":
+		s += " <string>..."
+	default:
+		s += " <" + f.Kind + ">"
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testTree builds a small tree with persistent flags on the root and a
+// command below a parent, and returns the root and the command.
+func testTree() (*Node, *Node) {
+	root := Root("faucet").
+		String("profile", "p", "", "profile to use").
+		Bool("quiet", "q", false, "say less")
+	show := root.Parent("droplets", "manage droplets").
+		Command("show", "show a droplet", "<id> [<field>]", func(*Context) error { return nil }).
+		Int("count", "n", 1, "how many").
+		Bool("wait", "", false, "wait for it")
+	return root, show
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args       []string
+		positional []string
+		profile    string
+		quiet      bool
+		count      int
+		err        string
+	}{
+		{args: []string{"1"}, positional: []string{"1"}, count: 1},
+		{args: []string{"--count=3", "1"}, positional: []string{"1"}, count: 3},
+		{args: []string{"--count", "3", "1"}, positional: []string{"1"}, count: 3},
+		{args: []string{"-n", "3", "1"}, positional: []string{"1"}, count: 3},
+		{args: []string{"1", "-q"}, positional: []string{"1"}, quiet: true, count: 1},
+		{args: []string{"--quiet=false", "1"}, positional: []string{"1"}, count: 1},
+		{args: []string{"--profile", "work", "1"}, positional: []string{"1"}, profile: "work", count: 1},
+		{args: []string{"-p=work", "1"}, positional: []string{"1"}, profile: "work", count: 1},
+		{args: []string{"--", "-q", "--count=3"}, positional: []string{"-q", "--count=3"}, count: 1},
+		{args: []string{"-5", "-1.5"}, positional: []string{"-5", "-1.5"}, count: 1},
+		{args: []string{"--count", "-2"}, count: -2},
+		{args: []string{"1", "--count"}, err: "flag needs a value: --count"},
+		{args: []string{"--count=x"}, err: `invalid value "x" for flag --count=x`},
+		{args: []string{"--quiet=maybe"}, err: `invalid value "maybe" for flag --quiet=maybe`},
+		{args: []string{"--nope"}, err: "unknown flag: --nope"},
+	}
+	for _, tt := range tests {
+		root, show := testTree()
+		root.resetFlags()
+		show.resetFlags()
+		positional, err := show.parse(tt.args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parse(%q) error = %v, want %s", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.args, err)
+			continue
+		}
+		ctx := &Context{Node: show}
+		if !reflect.DeepEqual(positional, tt.positional) {
+			t.Errorf("parse(%q) = %q, want %q", tt.args, positional, tt.positional)
+		}
+		if got := ctx.String("profile"); got != tt.profile {
+			t.Errorf("parse(%q): profile = %q, want %q", tt.args, got, tt.profile)
+		}
+		if got := ctx.Bool("quiet"); got != tt.quiet {
+			t.Errorf("parse(%q): quiet = %v, want %v", tt.args, got, tt.quiet)
+		}
+		if got := ctx.Int("count"); got != tt.count {
+			t.Errorf("parse(%q): count = %d, want %d", tt.args, got, tt.count)
+		}
+	}
+}
+
+func TestParseParent(t *testing.T) {
+	root, _ := testTree()
+	root.resetFlags()
+	rest, err := root.parse([]string{"-q", "droplets", "show", "--count=2", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"droplets", "show", "--count=2", "1"}
+	if !reflect.DeepEqual(rest, want) {
+		t.Errorf("parse = %q, want %q", rest, want)
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	_, show := testTree()
+	many := show.parent.Command("tag", "tag droplets", "<tag> <id>...", nil)
+	tests := []struct {
+		node       *Node
+		positional []string
+		err        string
+	}{
+		{show, []string{"1"}, ""},
+		{show, []string{"1", "name"}, ""},
+		{show, nil, "missing <id>"},
+		{show, []string{"1", "name", "extra"}, "too many arguments"},
+		{many, []string{"web"}, "missing <id>"},
+		{many, []string{"web", "1", "2", "3"}, ""},
+	}
+	for _, tt := range tests {
+		err := tt.node.checkArgs(tt.positional)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: checkArgs(%q) = %v, want %q", tt.node.Name, tt.positional, err, tt.err)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	got := parseArgs("<id> <new name> [<field>...]")
+	want := []Arg{{Name: "id"}, {Name: "new name"}, {Name: "field", Optional: true, Variadic: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseArgs = %+v, want %+v", got, want)
+	}
+}
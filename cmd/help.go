@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// ShowHelp is a CmdFunc that walks the tree below n along args and prints
+// the full help of the node it ends on.
+func (n *Node) ShowHelp(ctx *Context) error {
+	node := n
+	for _, name := range ctx.Args {
+		if node.Fn != nil {
+			break
+		}
+		child := node.child(name)
+		if child == nil {
+			if path := node.plugin(name); path != "" {
+				return node.runPlugin(path, []string{"--help"})
+			}
+			return node.unknownCommand(name)
+		}
+		node = child
+	}
+	printHelp(os.Stdout, node)
+	return nil
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "--help"
+}
+
+func printHelp(out io.Writer, n *Node) {
+	if n.Fn == nil {
+		printParentHelp(out, n)
+	} else {
+		printCommandHelp(out, n)
+		fmt.Fprintln(out)
+	}
+	if n.Description != "" {
+		fmt.Fprintf(out, "%s\n\n", n.Description)
+	}
+	if len(n.Aliases) > 0 {
+		fmt.Fprintf(out, "aliases: %s\n\n", strings.Join(n.Aliases, ", "))
+	}
+	if n.Long != "" {
+		fmt.Fprintf(out, "%s\n\n", strings.TrimSpace(n.Long))
+	}
+	printFlags(out, "flags", n.Flags)
+	printFlags(out, "global flags", n.inheritedFlags())
+	if len(n.Examples) > 0 {
+		fmt.Fprint(out, "examples:\n\n")
+		for _, example := range n.Examples {
+			fmt.Fprintf(out, "  %s\n", example)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func printParentHelp(out io.Writer, n *Node) {
+	fmt.Fprintf(out, "usage: %s\n\n", n.UsageLine())
+	fmt.Fprint(out, "commands:\n\n")
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 0, '\t', 0)
+	for _, child := range n.Children {
+		if !child.Hidden {
+			fmt.Fprintln(w, " ", child.Name, "\t", child.Description)
+		}
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+	if n.parent == nil {
+		if plugins := n.Plugins(); len(plugins) > 0 {
+			fmt.Fprint(out, "plugins:\n\n")
+			for _, p := range plugins {
+				fmt.Fprintf(out, "  %s\n", p)
+			}
+			fmt.Fprintln(out)
+		}
+	}
+}
+
+func printCommandHelp(out io.Writer, n *Node) {
+	fmt.Fprintf(out, "usage: %s\n", n.UsageLine())
+}
+
+func flagUsage(f *Flag) string {
+	if f.Kind != "bool" && f.Default != "" {
+		return fmt.Sprintf("%s (default %s)", f.Usage, f.Default)
+	}
+	return f.Usage
+}
+
+func printFlags(out io.Writer, title string, flags []*Flag) {
+	if len(flags) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%s:\n\n", title)
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 2, ' ', 0)
+	for _, f := range flags {
+		short := "   "
+		if f.Short != "" {
+			short = "-" + f.Short + ","
+		}
+		fmt.Fprintf(w, "  %s %s\t%s\n", short, flagSynopsis(f), flagUsage(f))
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+}
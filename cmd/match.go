@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+)
+
+// builtinAliases are given to every node created with one of these names.
+var builtinAliases = map[string][]string{
+	"list":    {"ls"},
+	"destroy": {"rm"},
+	"delete":  {"rm"},
+}
+
+// Alias adds alternative names for n.
+func (n *Node) Alias(names ...string) *Node {
+	n.Aliases = append(n.Aliases, names...)
+	return n
+}
+
+func (n *Node) matches(name string) bool {
+	if n.Name == name {
+		return true
+	}
+	for _, a := range n.Aliases {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup finds the child called name, either exactly, by alias or by a
+// prefix of exactly one child's name. Otherwise it returns every child the
+// prefix could mean.
+func (n *Node) lookup(name string) (*Node, []*Node) {
+	for _, c := range n.Children {
+		if c.matches(name) {
+			return c, nil
+		}
+	}
+	var candidates []*Node
+	for _, c := range n.Children {
+		if !c.Hidden && name != "" && strings.HasPrefix(c.Name, name) {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	return nil, candidates
+}
+
+func (n *Node) child(name string) *Node {
+	c, _ := n.lookup(name)
+	return c
+}
+
+// unknownCommand builds the error for a name that matched no child of n,
+// suggesting the closest names. Without a suggestion the parent help is
+// printed instead.
+func (n *Node) unknownCommand(name string) error {
+	_, candidates := n.lookup(name)
+	if len(candidates) > 1 {
+		var names []string
+		for _, c := range candidates {
+			names = append(names, c.Name)
+		}
+		return &UnknownCommandError{n.Path(), name, true, names}
+	}
+	suggestions := n.suggest(name)
+	if len(suggestions) == 0 {
+		printParentHelp(os.Stderr, n)
+	}
+	return &UnknownCommandError{n.Path(), name, false, suggestions}
+}
+
+// suggest returns the visible children within a small edit distance of
+// name, closest first.
+func (n *Node) suggest(name string) []string {
+	best := len(name)/3 + 1
+	if best > 2 {
+		best = 2
+	}
+	var suggestions []string
+	for _, c := range n.Children {
+		if c.Hidden {
+			continue
+		}
+		d := levenshtein(name, c.Name)
+		for _, a := range c.Aliases {
+			if ad := levenshtein(name, a); ad < d {
+				d = ad
+			}
+		}
+		switch {
+		case d < best:
+			best = d
+			suggestions = []string{c.Name}
+		case d == best:
+			suggestions = append(suggestions, c.Name)
+		}
+	}
+	return suggestions
+}
+
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
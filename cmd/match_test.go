@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"list", "list", 0},
+		{"lsit", "list", 2},
+		{"lst", "list", 1},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	root := Root("faucet")
+	root.Parent("droplets", "")
+	root.Parent("domains", "")
+	root.Parent("images", "")
+	root.Parent("debug", "").Hidden = true
+	tests := []struct {
+		name       string
+		want       string
+		candidates []string
+	}{
+		{"droplets", "droplets", nil},
+		{"dr", "droplets", nil},
+		{"i", "images", nil},
+		{"d", "", []string{"droplets", "domains"}},
+		{"de", "", nil},
+		{"debug", "debug", nil},
+		{"", "", nil},
+		{"x", "", nil},
+	}
+	for _, tt := range tests {
+		node, candidates := root.lookup(tt.name)
+		var got string
+		if node != nil {
+			got = node.Name
+		}
+		var names []string
+		for _, c := range candidates {
+			names = append(names, c.Name)
+		}
+		if got != tt.want || !reflect.DeepEqual(names, tt.candidates) {
+			t.Errorf("lookup(%q) = %q, %q, want %q, %q", tt.name, got, names, tt.want, tt.candidates)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	root := Root("faucet")
+	root.Parent("droplets", "")
+	root.Parent("images", "")
+	root.Parent("regions", "").Alias("r")
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"droplet", []string{"droplets"}},
+		{"imagse", []string{"images"}},
+		{"regoins", []string{"regions"}},
+		{"zzz", nil},
+	}
+	for _, tt := range tests {
+		if got := root.suggest(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
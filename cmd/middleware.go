@@ -0,0 +1,22 @@
+package cmd
+
+// Middleware wraps the CmdFunc of a command. It can inspect the node being
+// run through the Context, for example its Path or Destructive, and run
+// code before and after calling next, or not call it at all.
+type Middleware func(next CmdFunc) CmdFunc
+
+// Use adds middleware to n. It applies to every command below n, with the
+// middleware of ancestors running first.
+func (n *Node) Use(mw ...Middleware) *Node {
+	n.middleware = append(n.middleware, mw...)
+	return n
+}
+
+func (n *Node) wrap(fn CmdFunc) CmdFunc {
+	for node := n; node != nil; node = node.parent {
+		for i := len(node.middleware) - 1; i >= 0; i-- {
+			fn = node.middleware[i](fn)
+		}
+	}
+	return fn
+}
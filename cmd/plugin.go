@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Commands not found in the tree are looked up on PATH as executables named
+// after the command path joined with dashes, so "faucet droplets foo" runs
+// faucet-droplets-foo with the remaining arguments.
+
+func (n *Node) root() *Node {
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+func (n *Node) pluginName(name string) string {
+	return strings.Replace(n.Path(), " ", "-", -1) + "-" + name
+}
+
+// plugin returns the path of the executable for the child name, if any.
+func (n *Node) plugin(name string) string {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return ""
+	}
+	path, err := exec.LookPath(n.pluginName(name))
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func (n *Node) runPlugin(path string, args []string) error {
+	c := exec.Command(path, args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	c.Env = os.Environ()
+	if fn := n.root().PluginEnv; fn != nil {
+		env, err := fn(&Context{Node: n, Args: args})
+		if err != nil {
+			return err
+		}
+		c.Env = append(c.Env, env...)
+	}
+	return c.Run()
+}
+
+// Plugins returns the names of the plugins on PATH for the root of n's
+// tree, without the root name prefix.
+func (n *Node) Plugins() []string {
+	prefix := n.root().Name + "-"
+	seen := make(map[string]bool)
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			name := f.Name()
+			if !strings.HasPrefix(name, prefix) || f.IsDir() || f.Mode()&0111 == 0 {
+				continue
+			}
+			name = strings.TrimPrefix(name, prefix)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/sand"
+	"os"
+	"strconv"
+)
+
+const completeCommand = "__complete"
+
+func completion(ctx *cmd.Context) error {
+	switch ctx.Args[0] {
+	case "bash":
+		cmd.GenBashCompletion(os.Stdout, root, completeCommand)
+	case "zsh":
+		cmd.GenZshCompletion(os.Stdout, root, completeCommand)
+	case "fish":
+		cmd.GenFishCompletion(os.Stdout, root, completeCommand)
+	default:
+		return cmd.ErrInvalidArgs
+	}
+	return nil
+}
+
+// completing is set while candidates are found. Completion runs with stderr
+// thrown away, or in the middle of a line being edited, so it must never
+// ask for a secret: an encrypted api key or an apiKeyCommand is then only
+// used if already unlocked, and otherwise candidates come from the cache
+// alone.
+var completing bool
+
+var errWouldPrompt = errors.New("not asking for a secret while completing")
+
+func complete(ctx *cmd.Context) error {
+	completing = true
+	for _, c := range root.Complete(ctx.Args) {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+func registerCompleters(root *cmd.Node) {
+	root.CompleteArg("command", root.CompleteCommand)
+	root.CompleteArg("shell", func(args []string) []string {
+		return []string{"bash", "zsh", "fish"}
+	})
+	root.CompleteArg("droplet id", completeDroplets)
+	root.CompleteArg("domain id", completeDomains)
+	root.CompleteArg("record id", completeRecords)
+	root.CompleteArg("key id", completeKeys)
+	root.CompleteArg("image id", completeImages)
+	root.CompleteArg("region id", completeRegions)
+	root.CompleteArg("size id", completeSizes)
+	root.CompleteArg("profile", completeProfiles)
+}
+
+func candidate(id int, name string) string {
+	return strconv.Itoa(id) + "\t" + name
+}
+
+func completeDroplets(args []string) []string {
+	var droplets []*sand.Droplet
+	cached("droplets", &droplets, func() (interface{}, error) { return sand.GetDroplets() })
+	var candidates []string
+	for _, d := range droplets {
+		candidates = append(candidates, candidate(d.Id, d.Name))
+	}
+	return candidates
+}
+
+func completeDomains(args []string) []string {
+	var domains []*sand.Domain
+	cached("domains", &domains, func() (interface{}, error) { return sand.GetDomains() })
+	var candidates []string
+	for _, d := range domains {
+		candidates = append(candidates, candidate(d.Id, d.Name))
+	}
+	return candidates
+}
+
+func completeRecords(args []string) []string {
+	var records []*sand.Record
+	cached("records-"+args[0], &records, func() (interface{}, error) { return sand.GetRecords(args[0]) })
+	var candidates []string
+	for _, r := range records {
+		candidates = append(candidates, candidate(r.Id, r.Name))
+	}
+	return candidates
+}
+
+func completeKeys(args []string) []string {
+	var keys []*sand.Key
+	cached("keys", &keys, func() (interface{}, error) { return sand.GetKeys() })
+	var candidates []string
+	for _, k := range keys {
+		candidates = append(candidates, candidate(k.Id, k.Name))
+	}
+	return candidates
+}
+
+func completeImages(args []string) []string {
+	var images []*sand.Image
+	cached("images", &images, func() (interface{}, error) { return sand.GetImages() })
+	var candidates []string
+	for _, i := range images {
+		candidates = append(candidates, candidate(i.Id, i.Name))
+	}
+	return candidates
+}
+
+func completeRegions(args []string) []string {
+	var regions []*sand.Region
+	cached("regions", &regions, func() (interface{}, error) { return sand.GetRegions() })
+	var candidates []string
+	for _, r := range regions {
+		candidates = append(candidates, candidate(r.Id, r.Name))
+	}
+	return candidates
+}
+
+func completeSizes(args []string) []string {
+	var sizes []*sand.Size
+	cached("sizes", &sizes, func() (interface{}, error) { return sand.GetSizes() })
+	var candidates []string
+	for _, s := range sizes {
+		candidates = append(candidates, candidate(s.Id, s.Name))
+	}
+	return candidates
+}
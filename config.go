@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/fancy"
+	"github.com/whub/faucet/sand"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type Config struct {
+	ClientId           string             `json:"clientId,omitempty"`
+	ApiKey             string             `json:"apiKey,omitempty"`
+	ApiKeyCommand      string             `json:"apiKeyCommand,omitempty"`
+	Profiles           map[string]Profile `json:"profiles,omitempty"`
+	DefaultProfile     string             `json:"defaultProfile,omitempty"`
+	Theme              fancy.Theme        `json:"theme"`
+	Aliases            map[string]string  `json:"aliases,omitempty"`
+	AuditLog           string             `json:"auditLog,omitempty"`
+	ApiUrl             string             `json:"apiUrl,omitempty"`
+	Proxy              string             `json:"proxy,omitempty"`
+	CABundle           string             `json:"caBundle,omitempty"`
+	InsecureSkipVerify bool               `json:"insecureSkipVerify,omitempty"`
+	Timeout            string             `json:"timeout,omitempty"`
+}
+
+// Config files are read from these places, lowest precedence first, and
+// merged key by key: /etc/faucet/config.json, the user config file, the
+// nearest faucet.json in the current directory or one above it,
+// $FAUCET_CONFIG and --config. The last two must exist if given. The
+// environment variables in configEnv override them all.
+const (
+	systemConfigPath  = "/etc/faucet/config.json"
+	projectConfigName = "faucet.json"
+)
+
+var (
+	// configPath is the file that changes are written to: the highest
+	// precedence file found, or the user config file if there are none.
+	configPath string
+	config     Config
+	// configOrigins maps each key of the merged config, such as
+	// profiles.staging.clientId, to the file that set it.
+	configOrigins = make(map[string]string)
+)
+
+// configEnv lists the environment variables that override config keys.
+// DIGITALOCEAN_TOKEN comes before FAUCET_API_KEY so the latter wins when
+// both are set.
+var configEnv = []struct {
+	name, key string
+	isBool    bool
+}{
+	{"FAUCET_CLIENT_ID", "clientId", false},
+	{"DIGITALOCEAN_TOKEN", "apiKey", false},
+	{"FAUCET_API_KEY", "apiKey", false},
+	{"FAUCET_API_KEY_COMMAND", "apiKeyCommand", false},
+	{"FAUCET_API_URL", "apiUrl", false},
+	{"FAUCET_PROXY", "proxy", false},
+	{"FAUCET_CA_BUNDLE", "caBundle", false},
+	{"FAUCET_INSECURE_SKIP_VERIFY", "insecureSkipVerify", true},
+	{"FAUCET_TIMEOUT", "timeout", false},
+	{"FAUCET_AUDIT_LOG", "auditLog", false},
+	{"FAUCET_DEFAULT_PROFILE", "defaultProfile", false},
+}
+
+const missingCredentialsHelp = `faucet needs a DigitalOcean client id and api key. Supply them by any of:
+
+  faucet auth login
+  the FAUCET_CLIENT_ID and FAUCET_API_KEY environment variables
+    (DIGITALOCEAN_TOKEN is used when FAUCET_API_KEY is not set)
+  clientId and apiKey in a config file; see faucet help config
+  clientId and apiKeyCommand, a program that prints the api key
+  a profile, with faucet profile add`
+
+type configLayer struct {
+	path     string
+	explicit bool
+}
+
+func configLayers(flag string) []configLayer {
+	layers := []configLayer{{path: systemConfigPath}}
+	if path, err := userConfigPath(); err == nil {
+		layers = append(layers, configLayer{path: path})
+	}
+	if path := findProjectConfig(); path != "" {
+		layers = append(layers, configLayer{path: path})
+	}
+	if path := os.Getenv("FAUCET_CONFIG"); path != "" {
+		layers = append(layers, configLayer{path, true})
+	}
+	if flag != "" {
+		layers = append(layers, configLayer{flag, true})
+	}
+	return layers
+}
+
+func userConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "faucet", "config.json"), nil
+}
+
+// findProjectConfig returns the nearest faucet.json in the current
+// directory or one of its parents.
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// earlyFlag finds the value of the persistent flag name in args, or
+// "true" for a bool flag given without one. The config has to be loaded
+// before the command line is dispatched, since it defines aliases, so the
+// flags that affect loading are picked out first.
+func earlyFlag(args []string, name string, isBool bool) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return ""
+		case arg == "--"+name && isBool:
+			return "true"
+		case arg == "--"+name && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--"+name+"="):
+			return strings.TrimPrefix(arg, "--"+name+"=")
+		}
+	}
+	return ""
+}
+
+// loadConfig merges the config files. Finding none is not an error, so
+// help and the like work without credentials. With strict, a file holding
+// an api key that others can read is an error.
+func loadConfig(flag string, strict bool) {
+	merged := make(map[string]interface{})
+	for _, layer := range configLayers(flag) {
+		b, err := ioutil.ReadFile(layer.path)
+		if os.IsNotExist(err) && !layer.explicit {
+			continue
+		}
+		if err == nil {
+			var m map[string]interface{}
+			dec := json.NewDecoder(bytes.NewReader(b))
+			dec.UseNumber()
+			if err = dec.Decode(&m); err != nil {
+				err = fmt.Errorf("%s: %v", layer.path, err)
+			} else {
+				err = checkPerms(layer.path, m, strict)
+			}
+			mergeConfig(merged, m, "", layer.path)
+		}
+		if err != nil {
+			report.Error(err)
+			os.Exit(exitError)
+		}
+		configPath = layer.path
+	}
+	if configPath == "" {
+		configPath, _ = userConfigPath()
+	}
+	for _, e := range configEnv {
+		v := os.Getenv(e.name)
+		if v == "" {
+			continue
+		}
+		var value interface{} = v
+		if e.isBool {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				report.Error(fmt.Errorf("$%s: not a boolean: %s", e.name, v))
+				os.Exit(exitError)
+			}
+			value = b
+		}
+		mergeConfig(merged, map[string]interface{}{e.key: value}, "", "$"+e.name)
+	}
+	b, err := json.Marshal(merged)
+	if err == nil {
+		config = Config{Theme: fancy.DefaultTheme}
+		err = json.Unmarshal(b, &config)
+	}
+	if err != nil {
+		report.Error(err)
+		os.Exit(exitError)
+	}
+	fancy.Current = config.Theme
+	if config.ApiUrl != "" {
+		sand.BaseURL = config.ApiUrl
+	}
+	if config.Timeout != "" {
+		if apiTimeout, err = time.ParseDuration(config.Timeout); err != nil {
+			report.Error(fmt.Errorf("timeout: %v", err))
+			os.Exit(exitError)
+		}
+	}
+}
+
+var (
+	// apiTimeout bounds each API request unless --timeout is given.
+	apiTimeout time.Duration
+	transport  http.RoundTripper
+)
+
+// apiTransport makes the transport for API requests from the config the
+// first time it is needed.
+func apiTransport() (http.RoundTripper, error) {
+	if transport != nil {
+		return transport, nil
+	}
+	if config.InsecureSkipVerify {
+		report.Error(fmt.Errorf("WARNING: TLS certificate verification is off (insecureSkipVerify in %s); anyone between you and %s can read your api key",
+			configOrigin("insecureSkipVerify"), sand.BaseURL))
+	}
+	t, err := sand.NewTransport(sand.TransportOptions{
+		Proxy:              config.Proxy,
+		CABundle:           config.CABundle,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+	transport = t
+	return t, nil
+}
+
+// lazyTransport builds the API transport on the first request, so that a
+// proxy or caBundle that does not load only fails commands that use the
+// API.
+type lazyTransport struct{}
+
+func (lazyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t, err := apiTransport()
+	if err != nil {
+		return nil, err
+	}
+	return t.RoundTrip(req)
+}
+
+// mergeConfig copies src over dst, merging objects present in both, and
+// records origin as the source of every value it copies.
+func mergeConfig(dst, src map[string]interface{}, prefix, origin string) {
+	for k, v := range src {
+		path := prefix + k
+		if sv, ok := v.(map[string]interface{}); ok {
+			if dv, ok := dst[k].(map[string]interface{}); ok {
+				mergeConfig(dv, sv, path+".", origin)
+				continue
+			}
+		}
+		dst[k] = v
+		setOrigin(path, v, origin)
+	}
+}
+
+func setOrigin(path string, v interface{}, origin string) {
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, v := range m {
+			setOrigin(path+"."+k, v, origin)
+		}
+		return
+	}
+	configOrigins[path] = origin
+}
+
+// setByEnv reports whether key was set by an environment variable rather
+// than a config file.
+func setByEnv(key string) bool {
+	return strings.HasPrefix(configOrigins[key], "$")
+}
+
+// overrides reports whether origin, a config file or an environment
+// variable, takes precedence over the config file at path.
+func overrides(origin, path, flag string) bool {
+	if strings.HasPrefix(origin, "$") {
+		return true
+	}
+	after := false
+	for _, layer := range configLayers(flag) {
+		if after && layer.path == origin {
+			return true
+		}
+		after = after || layer.path == path
+	}
+	return false
+}
+
+// configOrigin returns the file that set key, or any value below it.
+func configOrigin(key string) string {
+	if origin, ok := configOrigins[key]; ok {
+		return origin
+	}
+	for k, origin := range configOrigins {
+		if strings.HasPrefix(k, key+".") {
+			return origin
+		}
+	}
+	return configPath
+}
+
+// updateConfig applies fn to the config file at path and writes it back.
+// fn sees only what is in that file, not the merged config. Only the keys
+// fn changed are rewritten, so everything else in the file is kept as it
+// was.
+func updateConfig(path string, fn func(c *Config) error) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	raw := make(map[string]json.RawMessage)
+	var c Config
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return err
+		}
+	}
+	before, err := configKeys(&c)
+	if err != nil {
+		return err
+	}
+	if err := fn(&c); err != nil {
+		return err
+	}
+	after, err := configKeys(&c)
+	if err != nil {
+		return err
+	}
+	for k, v := range after {
+		if string(before[k]) != string(v) {
+			raw[k] = v
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			delete(raw, k)
+		}
+	}
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFilePrivate(path, append(out, '\n'))
+}
+
+// writeFilePrivate replaces the file at path with b, leaving it readable by
+// its owner alone even if it was not before. The file is written beside
+// path and renamed over it, so a failed write leaves the old one intact.
+func writeFilePrivate(path string, b []byte) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func configKeys(c *Config) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]json.RawMessage)
+	return keys, json.Unmarshal(b, &keys)
+}
+
+func configShow(ctx *cmd.Context) error {
+	values, err := configKeys(&config)
+	if err != nil {
+		return err
+	}
+	flat := make(map[string]string)
+	for k, v := range values {
+		flattenConfig(flat, k, v)
+	}
+	if !ctx.Bool("origin") {
+		return printResult(ctx, flat, func() {
+			printConfig(flat, func(key string) string { return "" })
+		})
+	}
+	type origin struct {
+		Value  string `json:"value"`
+		Origin string `json:"origin"`
+	}
+	origins := make(map[string]origin)
+	for k, v := range flat {
+		origins[k] = origin{v, configOrigins[k]}
+	}
+	return printResult(ctx, origins, func() {
+		printConfig(flat, func(key string) string {
+			if origin, ok := configOrigins[key]; ok {
+				return origin
+			}
+			return "default"
+		})
+	})
+}
+
+// flattenConfig adds the leaves of the JSON value v to flat under dotted
+// keys, masking secrets.
+func flattenConfig(flat map[string]string, key string, v json.RawMessage) {
+	var m map[string]json.RawMessage
+	if json.Unmarshal(v, &m) == nil {
+		for k, v := range m {
+			flattenConfig(flat, key+"."+k, v)
+		}
+		return
+	}
+	var s string
+	if json.Unmarshal(v, &s) != nil {
+		s = string(v)
+	}
+	switch {
+	case isEncrypted(s):
+		s = "(encrypted)"
+	case strings.HasSuffix(key, "apiKey"):
+		s = maskSecret(s)
+	}
+	flat[key] = s
+}
+
+func printConfig(flat map[string]string, origin func(key string) string) {
+	var keys []string
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", k, flat[k], origin(k))
+	}
+	w.Flush()
+}
+
+// maskSecret hides all but the last four characters of s.
+func maskSecret(s string) string {
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", 8) + s[len(s)-4:]
+}
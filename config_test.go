@@ -0,0 +1,178 @@
+package main
+
+import (
+	"github.com/whub/faucet/sand"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEarlyFlag(t *testing.T) {
+	tests := []struct {
+		args   []string
+		name   string
+		isBool bool
+		want   string
+	}{
+		{[]string{"faucet", "droplets", "list"}, "config", false, ""},
+		{[]string{"faucet", "--config", "x.json", "droplets"}, "config", false, "x.json"},
+		{[]string{"faucet", "droplets", "--config=x.json"}, "config", false, "x.json"},
+		{[]string{"faucet", "droplets", "--config"}, "config", false, ""},
+		{[]string{"faucet", "--", "--config", "x.json"}, "config", false, ""},
+		{[]string{"faucet", "--strict", "droplets"}, "strict", true, "true"},
+		{[]string{"faucet", "--strict=false"}, "strict", true, "false"},
+		{[]string{"faucet", "--strictly"}, "strict", true, ""},
+	}
+	for _, tt := range tests {
+		if got := earlyFlag(tt.args, tt.name, tt.isBool); got != tt.want {
+			t.Errorf("earlyFlag(%q, %s) = %q, want %q", tt.args, tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestConfigLayers checks that each place config is read from overrides
+// the ones before it, key by key, and that the environment overrides them
+// all.
+func TestConfigLayers(t *testing.T) {
+	explicit := filepath.Join(t.TempDir(), "explicit.json")
+	if err := ioutil.WriteFile(explicit, []byte(`{"apiUrl": "http://explicit"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	flagged := filepath.Join(t.TempDir(), "flag.json")
+	if err := ioutil.WriteFile(flagged, []byte(`{"apiUrl": "http://flag"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	const user = `{"apiUrl": "http://user", "proxy": "http://proxy", "profiles": {"a": {"clientId": "user-a"}, "b": {"clientId": "user-b"}}}`
+	const project = `{"apiUrl": "http://project", "profiles": {"a": {"clientId": "project-a"}}}`
+	tests := []struct {
+		name       string
+		project    string
+		env        map[string]string
+		configFlag string
+		apiUrl     string
+		origin     string
+	}{
+		{"user", "", nil, "", "http://user", "config.json"},
+		{"project", project, nil, "", "http://project", "faucet.json"},
+		{"FAUCET_CONFIG", project, map[string]string{"FAUCET_CONFIG": explicit}, "", "http://explicit", "explicit.json"},
+		{"--config", project, map[string]string{"FAUCET_CONFIG": explicit}, flagged, "http://flag", "flag.json"},
+		{"env", project, map[string]string{"FAUCET_CONFIG": explicit, "FAUCET_API_URL": "http://env"}, flagged, "http://env", "$FAUCET_API_URL"},
+	}
+	for _, tt := range tests {
+		if err := loadTestConfig(t, user, tt.project, tt.env, tt.configFlag, ""); err != nil {
+			t.Fatal(err)
+		}
+		if config.ApiUrl != tt.apiUrl || !strings.HasSuffix(configOrigins["apiUrl"], tt.origin) {
+			t.Errorf("%s: apiUrl = %s from %s, want %s from %s", tt.name, config.ApiUrl, configOrigins["apiUrl"], tt.apiUrl, tt.origin)
+		}
+		// Keys the higher layers leave alone keep their values.
+		if config.Proxy != "http://proxy" {
+			t.Errorf("%s: proxy = %q, want the user file's", tt.name, config.Proxy)
+		}
+		if config.Profiles["b"].ClientId != "user-b" {
+			t.Errorf("%s: profile b = %+v, want the user file's", tt.name, config.Profiles["b"])
+		}
+	}
+	if err := loadTestConfig(t, user, project, nil, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Profiles["a"].ClientId; got != "project-a" {
+		t.Errorf("profile a clientId = %s, want the project file's", got)
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		apiKey   string
+		insecure bool
+	}{
+		{map[string]string{"DIGITALOCEAN_TOKEN": "token"}, "token", true},
+		{map[string]string{"DIGITALOCEAN_TOKEN": "token", "FAUCET_API_KEY": "key"}, "key", true},
+		{map[string]string{"FAUCET_INSECURE_SKIP_VERIFY": "1"}, "filekey", true},
+		{map[string]string{"FAUCET_INSECURE_SKIP_VERIFY": "false"}, "filekey", false},
+	}
+	for _, tt := range tests {
+		if err := loadTestConfig(t, `{"apiKey": "filekey", "insecureSkipVerify": true}`, "", tt.env, "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if config.ApiKey != tt.apiKey || config.InsecureSkipVerify != tt.insecure {
+			t.Errorf("%v: apiKey = %s, insecureSkipVerify = %v, want %s, %v", tt.env, config.ApiKey, config.InsecureSkipVerify, tt.apiKey, tt.insecure)
+		}
+	}
+}
+
+// TestProfilePrecedence covers the rules in faucet help profile: the
+// profile comes from --profile, else $FAUCET_PROFILE, else defaultProfile,
+// and credentials in the environment take the place of those of a default
+// profile set in a file, but not of a profile named any other way.
+func TestProfilePrecedence(t *testing.T) {
+	const user = `{
+		"clientId": "top", "apiKey": "topkey",
+		"defaultProfile": "work",
+		"profiles": {
+			"work": {"clientId": "work", "apiKey": "workkey"},
+			"home": {"clientId": "home", "apiKey": "homekey"}
+		}
+	}`
+	tests := []struct {
+		name         string
+		env          map[string]string
+		profileFlag  string
+		profile      string
+		clientId     string
+		apiKey       string
+		clientIdFrom string
+		apiKeyFrom   string
+	}{
+		{"file default", nil, "", "work", "work", "workkey", "config.json", "config.json"},
+		{"$FAUCET_PROFILE", map[string]string{"FAUCET_PROFILE": "home"}, "", "home", "home", "homekey", "config.json", "config.json"},
+		{"--profile", map[string]string{"FAUCET_PROFILE": "home"}, "work", "work", "work", "workkey", "config.json", "config.json"},
+		{"$FAUCET_DEFAULT_PROFILE", map[string]string{"FAUCET_DEFAULT_PROFILE": "home"}, "", "home", "home", "homekey", "config.json", "config.json"},
+		{"env credentials over file default",
+			map[string]string{"FAUCET_CLIENT_ID": "env", "FAUCET_API_KEY": "envkey"}, "",
+			"", "env", "envkey", "$FAUCET_CLIENT_ID", "$FAUCET_API_KEY"},
+		{"DIGITALOCEAN_TOKEN over file default",
+			map[string]string{"FAUCET_CLIENT_ID": "env", "DIGITALOCEAN_TOKEN": "token"}, "",
+			"", "env", "token", "$FAUCET_CLIENT_ID", "$DIGITALOCEAN_TOKEN"},
+		{"env api key laid over file default",
+			map[string]string{"FAUCET_API_KEY": "envkey"}, "",
+			"work", "work", "envkey", "config.json", "$FAUCET_API_KEY"},
+		{"env client id laid over file default",
+			map[string]string{"FAUCET_CLIENT_ID": "env"}, "",
+			"work", "env", "workkey", "$FAUCET_CLIENT_ID", "config.json"},
+		{"env credentials under --profile",
+			map[string]string{"FAUCET_CLIENT_ID": "env", "FAUCET_API_KEY": "envkey"}, "home",
+			"home", "home", "homekey", "config.json", "config.json"},
+		{"env credentials under $FAUCET_PROFILE",
+			map[string]string{"FAUCET_CLIENT_ID": "env", "FAUCET_API_KEY": "envkey", "FAUCET_PROFILE": "home"}, "",
+			"home", "home", "homekey", "config.json", "config.json"},
+		{"env credentials under $FAUCET_DEFAULT_PROFILE",
+			map[string]string{"FAUCET_CLIENT_ID": "env", "FAUCET_API_KEY": "envkey", "FAUCET_DEFAULT_PROFILE": "home"}, "",
+			"home", "home", "homekey", "config.json", "config.json"},
+	}
+	for _, tt := range tests {
+		if err := loadTestConfig(t, user, "", tt.env, "", tt.profileFlag); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if activeProfile != tt.profile || sand.ClientId != tt.clientId || sand.ApiKey != tt.apiKey {
+			t.Errorf("%s: profile %q with %s/%s, want %q with %s/%s", tt.name,
+				activeProfile, sand.ClientId, sand.ApiKey, tt.profile, tt.clientId, tt.apiKey)
+		}
+		if !strings.HasSuffix(clientIdFrom, tt.clientIdFrom) || !strings.HasSuffix(apiKeyFrom, tt.apiKeyFrom) {
+			t.Errorf("%s: credentials from %s and %s, want %s and %s", tt.name, clientIdFrom, apiKeyFrom, tt.clientIdFrom, tt.apiKeyFrom)
+		}
+	}
+	// Top-level credentials are used without a profile to select.
+	if err := loadTestConfig(t, `{"clientId": "top", "apiKey": "topkey"}`, "", nil, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if activeProfile != "" || sand.ClientId != "top" || sand.ApiKey != "topkey" {
+		t.Errorf("no profile: profile %q with %s/%s, want the top-level credentials", activeProfile, sand.ClientId, sand.ApiKey)
+	}
+	if err := loadTestConfig(t, user, "", nil, "", "nope"); err == nil {
+		t.Errorf("--profile nope: no error")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"golang.org/x/crypto/scrypt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Encrypted values are stored as encPrefix followed by the base64 of the
+// scrypt salt, the GCM nonce and the sealed value.
+const (
+	encPrefix = "enc:scrypt-aesgcm:"
+	saltSize  = 16
+)
+
+var errWrongPassphrase = errors.New("wrong passphrase, or the encrypted value is damaged")
+
+// passphrase is asked for at most once per process.
+var passphrase string
+
+func isEncrypted(s string) bool {
+	return strings.HasPrefix(s, encPrefix)
+}
+
+// getPassphrase returns $FAUCET_PASSPHRASE, or prompts for the passphrase.
+// With confirm it is asked for twice, for encrypting.
+func getPassphrase(confirm bool) (string, error) {
+	if passphrase != "" {
+		return passphrase, nil
+	}
+	if p := os.Getenv("FAUCET_PASSPHRASE"); p != "" {
+		passphrase = p
+		return p, nil
+	}
+	if completing {
+		return "", errWouldPrompt
+	}
+	p, err := promptLine("config passphrase: ", true)
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", errors.New("empty passphrase")
+	}
+	if confirm {
+		again, err := promptLine("again: ", true)
+		if err != nil {
+			return "", err
+		}
+		if again != p {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	passphrase = p
+	return p, nil
+}
+
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptValue(passphrase, plaintext string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(append(salt, nonce...), nonce, []byte(plaintext), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptValue(passphrase, value string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encPrefix))
+	if err != nil || len(b) < saltSize {
+		return "", errWrongPassphrase
+	}
+	aead, err := deriveKey(passphrase, b[:saltSize])
+	if err != nil {
+		return "", err
+	}
+	b = b[saltSize:]
+	if len(b) < aead.NonceSize() {
+		return "", errWrongPassphrase
+	}
+	plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errWrongPassphrase
+	}
+	return string(plaintext), nil
+}
+
+// decryptHelper returns a function for sand.ApiKeyFunc that decrypts
+// value when the api key is first needed, so commands that make no
+// requests never ask for the passphrase.
+func decryptHelper(value string) func() (string, error) {
+	return func() (string, error) {
+		p, err := getPassphrase(false)
+		if err != nil {
+			return "", err
+		}
+		return decryptValue(p, value)
+	}
+}
+
+// mapApiKeys replaces every api key in c with fn of it.
+func mapApiKeys(c *Config, fn func(string) (string, error)) error {
+	var err error
+	if c.ApiKey != "" {
+		if c.ApiKey, err = fn(c.ApiKey); err != nil {
+			return err
+		}
+	}
+	for name, p := range c.Profiles {
+		if p.ApiKey == "" {
+			continue
+		}
+		if p.ApiKey, err = fn(p.ApiKey); err != nil {
+			return err
+		}
+		c.Profiles[name] = p
+	}
+	return nil
+}
+
+// apiKeyFiles returns the config files that set an api key, judging each
+// by its own contents rather than the merged config, in which a key from
+// another file or the environment may hide it.
+func apiKeyFiles(flag string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, layer := range configLayers(flag) {
+		if seen[layer.path] {
+			continue
+		}
+		seen[layer.path] = true
+		b, err := ioutil.ReadFile(layer.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var c Config
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.path, err)
+		}
+		found := false
+		mapApiKeys(&c, func(key string) (string, error) {
+			found = true
+			return key, nil
+		})
+		if found {
+			files = append(files, layer.path)
+		}
+	}
+	return files, nil
+}
+
+func configEncrypt(ctx *cmd.Context) error {
+	return rewriteApiKeys(ctx, true)
+}
+
+func configDecrypt(ctx *cmd.Context) error {
+	return rewriteApiKeys(ctx, false)
+}
+
+// rewriteApiKeys encrypts or decrypts the api keys in every config file
+// that has one. Keys already in the wanted form are left alone.
+func rewriteApiKeys(ctx *cmd.Context, encrypt bool) error {
+	files, err := apiKeyFiles(ctx.String("config"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no api keys in any config file")
+	}
+	p, err := getPassphrase(encrypt)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		changed := 0
+		err := updateConfig(path, func(c *Config) error {
+			return mapApiKeys(c, func(key string) (string, error) {
+				if isEncrypted(key) == encrypt {
+					return key, nil
+				}
+				changed++
+				if encrypt {
+					return encryptValue(p, key)
+				}
+				return decryptValue(p, key)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if encrypt {
+			report.Infof("encrypted %d api keys in %s", changed, path)
+		} else {
+			report.Infof("decrypted %d api keys in %s", changed, path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncryptValue(t *testing.T) {
+	for _, plaintext := range []string{"", "0123456789abcdef", "key with spaces and ünïcode"} {
+		enc, err := encryptValue("hunter2", plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isEncrypted(enc) {
+			t.Errorf("encryptValue(%q) = %s, without the %s prefix", plaintext, enc, encPrefix)
+		}
+		if plaintext != "" && strings.Contains(enc, plaintext) {
+			t.Errorf("encryptValue(%q) = %s, which contains the plaintext", plaintext, enc)
+		}
+		got, err := decryptValue("hunter2", enc)
+		if err != nil {
+			t.Errorf("decryptValue(encryptValue(%q)): %v", plaintext, err)
+		} else if got != plaintext {
+			t.Errorf("decryptValue(encryptValue(%q)) = %q", plaintext, got)
+		}
+	}
+}
+
+func TestEncryptValueSalted(t *testing.T) {
+	a, _ := encryptValue("hunter2", "key")
+	b, _ := encryptValue("hunter2", "key")
+	if a == b {
+		t.Errorf("encrypting twice gave the same value %s", a)
+	}
+}
+
+func TestDecryptValueErrors(t *testing.T) {
+	enc, err := encryptValue("hunter2", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	damaged := enc[:len(enc)-4] + "AAA="
+	for _, value := range []string{damaged, encPrefix, encPrefix + "not base64!", encPrefix + "c2hvcnQ="} {
+		if _, err := decryptValue("hunter2", value); err != errWrongPassphrase {
+			t.Errorf("decryptValue(%q) error = %v, want %v", value, err, errWrongPassphrase)
+		}
+	}
+	if _, err := decryptValue("hunter3", enc); err != errWrongPassphrase {
+		t.Errorf("wrong passphrase: error = %v, want %v", err, errWrongPassphrase)
+	}
+}
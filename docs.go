@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"os"
+	"path/filepath"
+)
+
+func docs(ctx *cmd.Context) error {
+	format, dir := ctx.String("format"), ctx.String("dir")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	switch format {
+	case "man":
+		if err := cmd.GenManPages(dir, root); err != nil {
+			return err
+		}
+	case "markdown", "md":
+		path := filepath.Join(dir, root.Name+".md")
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := cmd.GenMarkdown(f, root); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+	report.Infof("wrote %s docs to %s", format, dir)
+	return nil
+}
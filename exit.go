@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/sand"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Exit codes returned by faucet.
+const (
+	exitOK          = 0   // success
+	exitError       = 1   // any failure not covered below
+	exitUsage       = 2   // bad arguments or unknown command
+	exitAuth        = 3   // the API rejected the credentials
+	exitNotFound    = 4   // the requested resource does not exist
+	exitAPI         = 5   // the API returned any other error
+	exitTimeout     = 6   // a request or wait timed out
+	exitInterrupted = 130 // interrupted by SIGINT or SIGTERM
+)
+
+const exitCodesHelp = `exit codes:
+
+  0    success
+  1    any failure not covered below
+  2    bad arguments or unknown command
+  3    the API rejected the credentials
+  4    the requested resource does not exist
+  5    the API returned any other error
+  6    a request or wait timed out
+  130  interrupted`
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var usageErr *cmd.UsageError
+	var unknownErr *cmd.UnknownCommandError
+	var apiErr *sand.APIError
+	var netErr net.Error
+	var execErr *exec.ExitError
+	switch {
+	case errors.As(err, &execErr) && execErr.ExitCode() > 0:
+		return execErr.ExitCode()
+	case errors.As(err, &usageErr), errors.As(err, &unknownErr):
+		return exitUsage
+	case errors.Is(err, sand.ErrNoCredentials):
+		return exitAuth
+	case errors.As(err, &apiErr):
+		if apiErr.Unauthorized() {
+			return exitAuth
+		}
+		if apiErr.NotFound() {
+			return exitNotFound
+		}
+		return exitAPI
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return exitTimeout
+	}
+	return exitError
+}
+
+// exitHooks are run by exitOnInterrupt before the process ends, since
+// deferred calls are not.
+var (
+	exitHooksMu sync.Mutex
+	exitHooks   = make(map[int]func())
+	nextHook    int
+)
+
+// onExit registers fn, such as putting the terminal back, to be run if a
+// signal ends the process. It returns a function that runs fn and
+// unregisters it, for the normal path.
+func onExit(fn func()) func() {
+	exitHooksMu.Lock()
+	defer exitHooksMu.Unlock()
+	id := nextHook
+	nextHook++
+	exitHooks[id] = fn
+	return func() {
+		exitHooksMu.Lock()
+		_, ok := exitHooks[id]
+		delete(exitHooks, id)
+		exitHooksMu.Unlock()
+		if ok {
+			fn()
+		}
+	}
+}
+
+func runExitHooks() {
+	exitHooksMu.Lock()
+	defer exitHooksMu.Unlock()
+	for id, fn := range exitHooks {
+		fn()
+		delete(exitHooks, id)
+	}
+}
+
+var errInterrupted = errors.New("interrupted")
+
+var (
+	interruptMu sync.Mutex
+	interruptFn func()
+)
+
+// onInterrupt makes the next SIGINT call fn instead of ending the process,
+// until the returned function is called. The shell uses it to cancel the
+// command it is running; a second SIGINT still ends the process.
+func onInterrupt(fn func()) func() {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	interruptFn = fn
+	return func() {
+		interruptMu.Lock()
+		defer interruptMu.Unlock()
+		interruptFn = nil
+	}
+}
+
+// exitOnInterrupt makes SIGINT and SIGTERM end the process with
+// exitInterrupted, leaving the cursor on a fresh line.
+func exitOnInterrupt() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range c {
+			interruptMu.Lock()
+			fn := interruptFn
+			interruptFn = nil
+			interruptMu.Unlock()
+			if fn != nil && sig == os.Interrupt {
+				fn()
+				continue
+			}
+			runExitHooks()
+			report.Prompt("\n")
+			report.Error(errInterrupted)
+			os.Exit(exitInterrupted)
+		}
+	}()
+}
@@ -2,16 +2,44 @@ package fancy
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 )
 
-type Style int
+// Style packs text attributes, a foreground color and a background color
+// into one value so styles can be combined with |, e.g. Bold|Red|BgWhite.
+type Style uint64
 
 const (
 	None Style = 0
 )
 
 const (
-	Black Style = 30 + iota
+	Bold Style = 1 << iota
+	Dim
+	Italic
+	Underline
+	Blink
+	Reverse
+)
+
+const (
+	colorBasic = 1 + iota
+	color256
+	colorRGB
+)
+
+const (
+	fgShift   = 8
+	bgShift   = 36
+	colorBits = 28
+	colorMask = 1<<colorBits - 1
+)
+
+const (
+	Black Style = (colorBasic | iota<<2) << fgShift
 	Red
 	Green
 	Yellow
@@ -21,24 +49,245 @@ const (
 	White
 )
 
+const (
+	BgBlack Style = (colorBasic | iota<<2) << bgShift
+	BgRed
+	BgGreen
+	BgYellow
+	BgBlue
+	BgMagenta
+	BgCyan
+	BgWhite
+)
+
+var attrNames = []string{"bold", "dim", "italic", "underline", "blink", "reverse"}
+
+var attrCodes = []int{1, 2, 3, 4, 5, 7}
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// Color256 returns a foreground color from the xterm 256-color palette.
+func Color256(n uint8) Style {
+	return Style(color256|uint64(n)<<2) << fgShift
+}
+
+// BgColor256 returns a background color from the xterm 256-color palette.
+func BgColor256(n uint8) Style {
+	return Style(color256|uint64(n)<<2) << bgShift
+}
+
+// RGB returns a 24-bit truecolor foreground color.
+func RGB(r, g, b uint8) Style {
+	return Style(colorRGB|rgb(r, g, b)<<2) << fgShift
+}
+
+// BgRGB returns a 24-bit truecolor background color.
+func BgRGB(r, g, b uint8) Style {
+	return Style(colorRGB|rgb(r, g, b)<<2) << bgShift
+}
+
+func rgb(r, g, b uint8) uint64 {
+	return uint64(r)<<16 | uint64(g)<<8 | uint64(b)
+}
+
+// Enabled turns styling off everywhere when false. It defaults to off when
+// NO_COLOR is set.
+var Enabled = os.Getenv("NO_COLOR") == ""
+
 func Print(s Style, a ...interface{}) {
-	changeStyle(s)
-	fmt.Print(a...)
-	changeStyle(None)
+	Fprint(os.Stdout, s, a...)
 }
 
 func Println(s Style, a ...interface{}) {
-	changeStyle(s)
-	fmt.Println(a...)
-	changeStyle(None)
+	Fprintln(os.Stdout, s, a...)
 }
 
 func Printf(s Style, format string, a ...interface{}) {
-	changeStyle(s)
-	fmt.Printf(format, a...)
-	changeStyle(None)
+	Fprintf(os.Stdout, s, format, a...)
+}
+
+// The F variants only style output written to a terminal, so redirected
+// output stays free of escape sequences.
+
+func Fprint(w io.Writer, s Style, a ...interface{}) {
+	fmt.Fprint(w, Sprint(forWriter(w, s), a...))
+}
+
+func Fprintln(w io.Writer, s Style, a ...interface{}) {
+	fmt.Fprint(w, Sprintln(forWriter(w, s), a...))
+}
+
+func Fprintf(w io.Writer, s Style, format string, a ...interface{}) {
+	fmt.Fprint(w, Sprintf(forWriter(w, s), format, a...))
+}
+
+func forWriter(w io.Writer, s Style) Style {
+	if !IsTerminal(w) {
+		return None
+	}
+	return s
+}
+
+func Sprint(s Style, a ...interface{}) string {
+	return wrap(s, fmt.Sprint(a...))
+}
+
+func Sprintln(s Style, a ...interface{}) string {
+	text := fmt.Sprintln(a...)
+	return wrap(s, text[:len(text)-1]) + "\n"
+}
+
+func Sprintf(s Style, format string, a ...interface{}) string {
+	return wrap(s, fmt.Sprintf(format, a...))
+}
+
+func wrap(s Style, text string) string {
+	if s == None || !Enabled {
+		return text
+	}
+	return s.sequence() + text + None.sequence()
+}
+
+func (s Style) sequence() string {
+	return "\x1b[" + strings.Join(s.codes(), ";") + "m"
+}
+
+func (s Style) codes() []string {
+	if s == None {
+		return []string{"0"}
+	}
+	var codes []string
+	for i, code := range attrCodes {
+		if s&(1<<uint(i)) != 0 {
+			codes = append(codes, strconv.Itoa(code))
+		}
+	}
+	codes = append(codes, colorCodes(s.fg(), 30)...)
+	codes = append(codes, colorCodes(s.bg(), 40)...)
+	return codes
+}
+
+func (s Style) fg() uint64 {
+	return uint64(s>>fgShift) & colorMask
+}
+
+func (s Style) bg() uint64 {
+	return uint64(s>>bgShift) & colorMask
+}
+
+func colorCodes(c uint64, base int) []string {
+	v := c >> 2
+	switch c & 3 {
+	case colorBasic:
+		return []string{strconv.Itoa(base + int(v))}
+	case color256:
+		return []string{strconv.Itoa(base + 8), "5", strconv.Itoa(int(v))}
+	case colorRGB:
+		return []string{strconv.Itoa(base + 8), "2",
+			strconv.Itoa(int(v >> 16 & 0xff)), strconv.Itoa(int(v >> 8 & 0xff)), strconv.Itoa(int(v & 0xff))}
+	}
+	return nil
+}
+
+// String returns the style in the form accepted by ParseStyle.
+func (s Style) String() string {
+	if s == None {
+		return "none"
+	}
+	var words []string
+	for i, name := range attrNames {
+		if s&(1<<uint(i)) != 0 {
+			words = append(words, name)
+		}
+	}
+	if c := s.fg(); c != 0 {
+		words = append(words, colorName(c))
+	}
+	if c := s.bg(); c != 0 {
+		words = append(words, "bg:"+colorName(c))
+	}
+	return strings.Join(words, " ")
+}
+
+func colorName(c uint64) string {
+	v := c >> 2
+	switch c & 3 {
+	case colorBasic:
+		return colorNames[v]
+	case color256:
+		return strconv.Itoa(int(v))
+	}
+	return fmt.Sprintf("#%06x", v)
+}
+
+// ParseStyle parses a space separated list of attributes and colors such
+// as "bold red", "underline 208" or "#ff8800 bg:#202020". Colors are a basic
+// color name, a 256-color palette index or a #rrggbb hex triplet, and are
+// applied to the background when prefixed with "bg:".
+func ParseStyle(str string) (Style, error) {
+	s := None
+	for _, word := range strings.FieldsFunc(strings.ToLower(str), isSeparator) {
+		if word == "none" {
+			continue
+		}
+		if i := indexOf(attrNames, word); i >= 0 {
+			s |= 1 << uint(i)
+			continue
+		}
+		shift := uint(fgShift)
+		if strings.HasPrefix(word, "bg:") {
+			word = word[3:]
+			shift = bgShift
+		}
+		c, err := parseColor(word)
+		if err != nil {
+			return None, err
+		}
+		s = s&^(colorMask<<shift) | Style(c)<<shift
+	}
+	return s, nil
+}
+
+func isSeparator(r rune) bool {
+	return r == ' ' || r == '\t' || r == '+' || r == '|' || r == ','
+}
+
+func parseColor(word string) (uint64, error) {
+	if i := indexOf(colorNames, word); i >= 0 {
+		return colorBasic | uint64(i)<<2, nil
+	}
+	if strings.HasPrefix(word, "#") {
+		v, err := strconv.ParseUint(word[1:], 16, 32)
+		if err != nil || len(word) != 7 {
+			return 0, fmt.Errorf("invalid color: %s", word)
+		}
+		return colorRGB | v<<2, nil
+	}
+	v, err := strconv.ParseUint(word, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid style: %s", word)
+	}
+	return color256 | v<<2, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s Style) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
 }
 
-func changeStyle(s Style) {
-	fmt.Printf("\x1b[%dm", int(s))
+func (s *Style) UnmarshalText(b []byte) error {
+	parsed, err := ParseStyle(string(b))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
 }
@@ -0,0 +1,135 @@
+package fancy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const (
+	spinnerInterval = 100 * time.Millisecond
+	barWidth        = 30
+)
+
+// IsTerminal reports whether w is a terminal that can be redrawn in place.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// Spinner animates a status message while a step is in progress. When the
+// writer is not a terminal it prints "msg... " up front and the result when
+// stopped, so logs get one plain line per step.
+type Spinner struct {
+	w    io.Writer
+	msg  string
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewSpinner(w io.Writer, msg string) *Spinner {
+	return &Spinner{w: w, msg: msg}
+}
+
+func StartSpinner(w io.Writer, msg string) *Spinner {
+	s := NewSpinner(w, msg)
+	s.Start()
+	return s
+}
+
+func (s *Spinner) Start() {
+	if !IsTerminal(s.w) {
+		fmt.Fprint(s.w, s.msg+"... ")
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.spin()
+}
+
+func (s *Spinner) spin() {
+	defer close(s.done)
+	t := time.NewTicker(spinnerInterval)
+	defer t.Stop()
+	for i := 0; ; i++ {
+		fmt.Fprintf(s.w, "\r%s %s... ", spinnerFrames[i%len(spinnerFrames)], s.msg)
+		select {
+		case <-s.stop:
+			fmt.Fprint(s.w, "\r\x1b[K"+s.msg+"... ")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Stop ends the animation and prints OK, or FAILED if err is non-nil.
+func (s *Spinner) Stop(err error) {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	if err != nil {
+		Fprintln(s.w, Current.Error, "FAILED")
+		return
+	}
+	Fprintln(s.w, Current.OK, "OK")
+}
+
+// Bar draws a percentage progress bar. When the writer is not a terminal it
+// prints a plain line each time the percentage changes.
+type Bar struct {
+	w       io.Writer
+	msg     string
+	tty     bool
+	percent int
+	mu      sync.Mutex
+}
+
+func NewBar(w io.Writer, msg string) *Bar {
+	return &Bar{w: w, msg: msg, tty: IsTerminal(w), percent: -1}
+}
+
+func (b *Bar) Set(percent int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	if percent == b.percent {
+		return
+	}
+	b.percent = percent
+	if !b.tty {
+		fmt.Fprintf(b.w, "%s... %d%%\n", b.msg, percent)
+		return
+	}
+	filled := barWidth * percent / 100
+	fmt.Fprintf(b.w, "\r%s [%s%s] %3d%%", b.msg,
+		Sprint(Current.OK, strings.Repeat("#", filled)), strings.Repeat(" ", barWidth-filled), percent)
+}
+
+// Stop finishes the bar with OK, or FAILED if err is non-nil.
+func (b *Bar) Stop(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tty && b.percent >= 0 {
+		fmt.Fprint(b.w, "\r\x1b[K")
+	}
+	fmt.Fprint(b.w, b.msg+"... ")
+	if err != nil {
+		Fprintln(b.w, Current.Error, "FAILED")
+		return
+	}
+	Fprintln(b.w, Current.OK, "OK")
+}
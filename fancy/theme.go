@@ -0,0 +1,17 @@
+package fancy
+
+// Theme maps the roles faucet prints in to styles, so teams can remap the
+// colors from faucet.json.
+type Theme struct {
+	OK    Style `json:"ok"`
+	Error Style `json:"error"`
+	Name  Style `json:"name"`
+}
+
+var DefaultTheme = Theme{
+	OK:    Green,
+	Error: Red,
+	Name:  Blue,
+}
+
+var Current = DefaultTheme
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,195 +11,368 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
+	"time"
 )
 
-type Config struct {
-	ClientId string `json:"clientId"`
-	ApiKey   string `json:"apiKey"`
-}
+const eventPollInterval = 2 * time.Second
 
-func loadConfig() {
-	f, err := os.Open("faucet.json")
-	if err != nil {
-		fancy.Println(fancy.Red, err)
-		os.Exit(1)
-	}
-	defer f.Close()
-	dec := json.NewDecoder(f)
-	config := Config{}
-	err = dec.Decode(&config)
-	if err != nil {
-		fancy.Println(fancy.Red, err)
-		os.Exit(1)
-	}
-	sand.ClientId = config.ClientId
-	sand.ApiKey = config.ApiKey
-}
+var root *cmd.Node
 
 func main() {
-	loadConfig()
-
-	root := cmd.Root(os.Args[0])
+	exitOnInterrupt()
+	loadConfig(earlyFlag(os.Args, "config", false), earlyFlag(os.Args, "strict", true) == "true")
+
+	root = cmd.Root(filepath.Base(os.Args[0]))
+	root.String("config", "", "", "read this config file over the others").
+		Bool("strict", "", false, "refuse config files with an api key that others can read").
+		String("profile", "p", "", "use the named profile's credentials").
+		String("output", "o", "text", "output format: text or json").
+		Duration("timeout", "", 0, "give up on API requests and waits after this long").
+		Bool("yes", "y", false, "do not ask before destructive commands").
+		Bool("quiet", "q", false, "only print results and errors").
+		Bool("verbose", "v", false, "print extra detail, such as timings").
+		Bool("debug", "", false, "log every API request and response, with credentials redacted")
+	root.Use(globals, timing, audit, confirm)
+	root.Long = `Manage DigitalOcean droplets, domains, keys and images. Run faucet help config
+for where credentials and settings are read from.
+
+Executables named faucet-<command> on PATH are run as plugin commands.
+
+` + exitCodesHelp
 
 	droplets := root.Parent("droplets", "manage droplets")
 	droplets.Command("list", "list droplets", "", dropletsList)
 	droplets.Command("show", "show details for a droplet", "<droplet id>", dropletsShow)
-	droplets.Command("new", "create a new droplet", "", dropletsNew)
+	dropletNew := droplets.Command("new", "create a new droplet", "", dropletsNew)
+	dropletNew.Long = `Prompts for a name, then lists the available sizes, images, regions and ssh
+keys and asks for one of each. Several key ids may be given separated by
+commas.`
 	droplets.Command("ssh", "ssh into a droplet", "<droplet id>", dropletsSSH)
 	droplets.Command("scp", "scp a file to a droplet", "<file> <droplet id>", dropletsSCP)
 	droplets.Command("open", "open the droplet's ip address in a browser", "<droplet id>", dropletsOpen)
-	droplets.Command("shutdown", "cleanly shutdown a droplet", "<droplet id>", dropletsShutdown)
-	droplets.Command("reboot", "cleanly reboot a droplet", "<droplet id>", dropletsReboot)
-	droplets.Command("poweroff", "power off a droplet", "<droplet id>", dropletsPoweroff)
-	droplets.Command("poweron", "power on a droplet", "<droplet id>", dropletsPoweron)
-	droplets.Command("powercycle", "power off then power on a droplet", "<droplet id>", dropletsPowercycle)
-	droplets.Command("resize", "change the size of a droplet", "<droplet id> <size id>", dropletsResize)
-	droplets.Command("snapshot", "take a snapshot of a droplet", "<droplet id> <name>", dropletsSnapshot)
-	droplets.Command("restore", "revert a droplet back to a snapshot", "<droplet id> <image id>", dropletsRestore)
-	droplets.Command("rebuild", "reinstall an image to a droplet", "<droplet id> <image id>", dropletsRebuild)
-	droplets.Command("rename", "change the name of a droplet", "<droplet id> <name>", dropletsRename)
-	droplets.Command("resetpass", "reset the root password of a droplet", "<droplet id>", dropletsResetpass)
-	droplets.Command("destroy", "destroy a droplet", "<droplet id> <scrub data?>", dropletsDestroy)
+	eventCommand(droplets, "shutdown", "cleanly shutdown a droplet", "<droplet id>", dropletsShutdown)
+	eventCommand(droplets, "reboot", "cleanly reboot a droplet", "<droplet id>", dropletsReboot)
+	eventCommand(droplets, "poweroff", "power off a droplet", "<droplet id>", dropletsPoweroff)
+	eventCommand(droplets, "poweron", "power on a droplet", "<droplet id>", dropletsPoweron)
+	eventCommand(droplets, "powercycle", "power off then power on a droplet", "<droplet id>", dropletsPowercycle)
+	dropletResize := eventCommand(droplets, "resize", "change the size of a droplet", "<droplet id> <size id>", dropletsResize)
+	dropletResize.Long = "The droplet must be powered off first. Run `faucet sizes` for the size ids."
+	dropletResize.Examples = []string{
+		"faucet droplets poweroff --wait 123",
+		"faucet droplets resize 123 66",
+	}
+	eventCommand(droplets, "snapshot", "take a snapshot of a droplet", "<droplet id> <name>", dropletsSnapshot)
+	eventCommand(droplets, "restore", "revert a droplet back to a snapshot", "<droplet id> <image id>", dropletsRestore).Destructive = true
+	eventCommand(droplets, "rebuild", "reinstall an image to a droplet", "<droplet id> <image id>", dropletsRebuild).Destructive = true
+	eventCommand(droplets, "rename", "change the name of a droplet", "<droplet id> <name>", dropletsRename)
+	eventCommand(droplets, "resetpass", "reset the root password of a droplet", "<droplet id>", dropletsResetpass)
+	dropletDestroy := eventCommand(droplets, "destroy", "destroy a droplet", "<droplet id>", dropletsDestroy)
+	dropletDestroy.Destructive = true
+	dropletDestroy.Bool("scrub", "s", false, "overwrite the disk before it is released")
+	dropletDestroy.Examples = []string{"faucet droplets destroy --scrub 123"}
 
 	domains := root.Parent("domains", "manage domains")
 	domains.Command("list", "list domains", "", domainsList)
 	domains.Command("show", "show details of a domain", "<domain id>", domainsShow)
 	domains.Command("new", "create a new domain", "", domainsNew)
-	domains.Command("destroy", "destroy a domain", "<domain id>", domainsDestroy)
+	domains.Command("destroy", "destroy a domain", "<domain id>", domainsDestroy).Destructive = true
 
 	records := domains.Parent("records", "manage records")
 	records.Command("list", "list records", "<domain id>", recordsList)
 	records.Command("show", "show details for a record", "<domain id> <record id>", recordsShow)
 	records.Command("new", "create a new record", "<domain id>", recordsNew)
 	records.Command("edit", "edit a record", "<domain id> <record id>", recordsEdit)
-	records.Command("destroy", "destroy a record", "<domain id> <record id>", recordsDestroy)
+	records.Command("destroy", "destroy a record", "<domain id> <record id>", recordsDestroy).Destructive = true
 
 	keys := root.Parent("keys", "manage ssh keys")
 	keys.Command("list", "list keys", "", keysList)
 	keys.Command("show", "show details of a key", "<key id>", keysShow)
 	keys.Command("add", "add ~/.ssh/id_rsa.pub to the key list", "<name>", keysAdd)
 	keys.Command("update", "change a key to match ~/.ssh/id_rsa.pub", "<key id>", keysUpdate)
-	keys.Command("delete", "delete a key", "<key id>", keysDelete)
+	keys.Command("delete", "delete a key", "<key id>", keysDelete).Destructive = true
 
 	images := root.Parent("images", "manage images")
 	images.Command("list", "list images", "", imagesList)
 	images.Command("show", "show details of an image", "<image id>", imagesShow)
-	images.Command("transfer", "transfer an image to a region", "<image id> <region id>", imagesTransfer)
-	images.Command("destroy", "destroy an image", "<image id>", imagesDestroy)
+	eventCommand(images, "transfer", "transfer an image to a region", "<image id> <region id>", imagesTransfer)
+	images.Command("destroy", "destroy an image", "<image id>", imagesDestroy).Destructive = true
 
 	root.Command("regions", "list available regions", "", regions)
 	root.Command("sizes", "list available sizes", "", sizes)
 	root.Command("event", "show progress of an event", "<event id>", event)
-	root.Command("help", "show usage for a specific command", "<command>", help)
+	waitCmd := root.Command("wait", "wait for an event to finish", "<event id>", wait)
+	waitCmd.Long = "Polls the event until it is done, showing its progress."
+	waitCmd.Examples = []string{"faucet wait 7501"}
+	helpCmd := root.Command("help", "show usage for a specific command", "[<command>...]", root.ShowHelp)
+	helpCmd.Examples = []string{
+		"faucet help droplets",
+		"faucet help domains records new",
+	}
+	completionCmd := root.Command("completion", "generate a shell completion script", "<shell>", completion)
+	completionCmd.Long = `Prints a completion script for bash, zsh or fish. Droplet, domain, key and
+image ids are completed from a local cache of recent listings.`
+	completionCmd.Examples = []string{
+		"source <(faucet completion bash)",
+		"faucet completion zsh > \"${fpath[1]}/_faucet\"",
+		"faucet completion fish > ~/.config/fish/completions/faucet.fish",
+	}
+	completeCmd := root.Command(completeCommand, "print completion candidates", "[<word>...]", complete)
+	completeCmd.Hidden = true
+	registerCompleters(root)
+
+	auth := root.Parent("auth", "log in and out")
+	authLogin := auth.Command("login", "prompt for credentials, check them and save them", "", authLogin)
+	authLogin.Long = `Prompts for a client id and api key, checks them against the API and writes
+them to the user config file, readable only by you.`
+	auth.Command("status", "show which credentials are in use and check them", "", authStatus)
+	auth.Command("logout", "remove the credentials from the user config file", "", authLogout)
+
+	profile := root.Parent("profile", "manage account profiles")
+	profile.Long = `A profile holds the credentials for one account. The profile used is the
+one given with --profile, else $FAUCET_PROFILE, else the default set with
+profile use. Without any of those, the clientId and apiKey at the top of
+faucet.json are used. FAUCET_CLIENT_ID and FAUCET_API_KEY take the place
+of the default profile's credentials, but not of one named with --profile
+or $FAUCET_PROFILE. profile add and profile use write to the user config
+file, so credentials never end up in a project's faucet.json.`
+	profile.Command("list", "list profiles", "", profileList)
+	profile.Command("use", "make a profile the default", "<profile>", profileUse)
+	profileAdd := profile.Command("add", "add a profile, prompting for its credentials", "<name>", profileAdd)
+	profileAdd.Examples = []string{
+		"faucet profile add staging",
+		"faucet --profile staging droplets list",
+	}
+	profile.Command("remove", "remove a profile", "<profile>", profileRemove).Destructive = true
+
+	configCmd := root.Parent("config", "inspect the configuration")
+	configCmd.Long = `Config files are read from these places and merged, each overriding the
+ones before it key by key:
+
+  /etc/faucet/config.json
+  $XDG_CONFIG_HOME/faucet/config.json, or the platform's user config dir
+  faucet.json in the current directory or the nearest one above it
+  $FAUCET_CONFIG
+  --config
+
+Commands that change the config write to the last of these that exists,
+or create the user config file.
+
+These environment variables override the files: FAUCET_CLIENT_ID,
+FAUCET_API_KEY (or DIGITALOCEAN_TOKEN), FAUCET_API_KEY_COMMAND,
+FAUCET_API_URL, FAUCET_PROXY, FAUCET_CA_BUNDLE,
+FAUCET_INSECURE_SKIP_VERIFY, FAUCET_TIMEOUT, FAUCET_AUDIT_LOG and
+FAUCET_DEFAULT_PROFILE. Credentials in the environment also win over a
+defaultProfile set in a file.
+
+Instead of apiKey, a config or profile may set apiKeyCommand, which is run
+with sh the first time the api key is needed; the first line it prints is
+used as the key. apiKey wins when both are set.
+
+Besides credentials, a config may set apiUrl to talk to a compatible
+stand-in for the API, proxy (a URL), caBundle (a PEM file of extra CAs to
+trust), timeout (per request, such as 30s) and insecureSkipVerify, which
+turns off certificate checks and should only ever be used for testing.
+
+A file holding a plaintext api key that group or others can read, or that
+belongs to another user, draws a warning, or is refused with --strict.`
+	configShow := configCmd.Command("show", "print the merged configuration", "", configShow)
+	configShow.Bool("origin", "", false, "show the file each value came from")
+	configShow.Examples = []string{"faucet config show --origin"}
+	configEncrypt := configCmd.Command("encrypt", "encrypt the api keys in the config files", "", configEncrypt)
+	configEncrypt.Long = `Encrypts every api key in the config files with a passphrase, using scrypt
+and AES-GCM. The passphrase is read from $FAUCET_PASSPHRASE, or asked for
+the first time a command needs an api key.`
+	configCmd.Command("decrypt", "decrypt the api keys in the config files", "", configDecrypt)
+	configCmd.Command("fix-perms", "make the config files readable only by you", "", configFixPerms)
+
+	alias := root.Parent("alias", "manage command aliases")
+	alias.Command("list", "list aliases", "", aliasList)
+	aliasSet := alias.Command("set", "define an alias", "<name> <command>...", aliasSet)
+	aliasSet.Long = `The command may refer to the arguments given to the alias as $1 to $9, or
+to all of them as $@. Unless it uses $@, the arguments after the highest one
+it refers to are appended, so flags may follow them. The command may start
+with global flags such as --profile. Quote the command, or put -- before
+it, when it contains flags.`
+	aliasSet.Examples = []string{
+		"faucet alias set web-reboot 'droplets reboot 123 --wait'",
+		"faucet alias set rs -- droplets resize --wait $1 66",
+	}
+	alias.Command("delete", "delete an alias", "<name>", aliasDelete)
+	shellCmd := root.Command("shell", "start an interactive faucet shell", "", shell)
+	shellCmd.Long = `Reads commands line by line and runs them without the faucet prefix, keeping
+the connection and the cached regions, sizes and images warm between them.
+Tab completes commands and resource ids, the up and down arrows walk the
+history, and exit or ctrl-d leaves.`
+
+	apiCmd := root.Command("api", "make a request to any API path and print the JSON", "<path>", api)
+	apiCmd.String("method", "X", "", "the HTTP method (default GET)").
+		Strings("field", "F", "add a key=value parameter to the request").
+		String("jq", "", "", "print only the values at this path, such as .droplets[].id")
+	apiCmd.Long = `Sends a request with your credentials to a path under the API url, for
+endpoints that have no command of their own, and prints the response. Fields
+are sent as query parameters, which is how the API takes them. Errors and
+exit codes are the same as for every other command.
+
+The API makes changes through GET requests too, so a request that fails
+with a server error is only retried when -X GET or -X HEAD is given to say
+it changes nothing. One turned away with 429 is always retried.`
+	apiCmd.Examples = []string{
+		"faucet api /droplets/",
+		"faucet api /droplets/ --jq '.droplets[].name'",
+		"faucet api /domains/new -F name=example.com -F ip_address=203.0.113.7",
+	}
+
+	batchCmd := root.Command("batch", "run the commands in a file", "<file>", batch)
+	batchCmd.Bool("keep-going", "k", false, "run the remaining commands after one fails").
+		Bool("wait", "w", false, "wait for the event started by each command to finish")
+	batchCmd.Long = `Runs one command per line, without the faucet prefix, and prints a table of
+the results. Blank lines and lines starting with # are skipped, and - reads
+the commands from stdin. Without --keep-going the first failure stops the
+batch and the rest are reported as skipped.`
+	batchCmd.Examples = []string{
+		"faucet batch --wait maintenance.txt",
+		"faucet batch --keep-going --yes - < cleanup.txt",
+	}
+
+	docsCmd := root.Command("docs", "generate man pages or a markdown reference", "", docs)
+	docsCmd.String("format", "f", "markdown", "man or markdown").
+		String("dir", "d", ".", "directory to write to")
+	docsCmd.Long = `Writes one man page per command, or a single faucet.md reference, generated
+from the command tree.`
+	docsCmd.Examples = []string{
+		"faucet docs --format man --dir out/",
+		"faucet docs --format markdown --dir docs/",
+	}
+
+	root.UserAliases = config.Aliases
+	root.PluginEnv = pluginEnv
 
 	err := root.Dispatch(os.Args, 1)
 	if err != nil {
-		fancy.Println(fancy.Red, err)
+		printError(err)
+		os.Exit(exitCode(err))
 	}
 }
 
-func dropletsList(args []string) error {
-	if len(args) != 0 {
-		return cmd.ErrInvalidArgs
+func printError(err error) {
+	if err == nil {
+		return
 	}
-	fmt.Print("fetching droplets... ")
-	droplets, err := sand.GetDroplets()
-	if err != nil {
-		return err
+	// Subprocesses such as ssh and plugins report their own failures.
+	if _, ok := err.(*exec.ExitError); !ok {
+		report.Error(err)
 	}
-	fancy.Println(fancy.Green, "OK")
-	if len(droplets) == 0 {
-		fmt.Println("No droplets.")
+	if errors.Is(err, sand.ErrNoCredentials) {
+		report.Infof("\n%s", missingCredentialsHelp)
 	}
-	for _, d := range droplets {
-		DropletPrint(d)
+}
+
+// printResult writes v to stdout as JSON when --output is json, and
+// otherwise calls text to print it for people.
+func printResult(ctx *cmd.Context, v interface{}, text func()) error {
+	if ctx.String("output") == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
 	}
+	text()
 	return nil
 }
 
-func dropletsShow(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
+func dropletsList(ctx *cmd.Context) error {
+	spin := report.Step("fetching droplets")
+	droplets, err := sand.GetDroplets()
+	spin.Stop(err)
+	if err != nil {
+		return err
 	}
-	fmt.Print("fetching droplet... ")
-	d, err := sand.GetDroplet(args[0])
+	writeCache("droplets", droplets)
+	return printResult(ctx, droplets, func() {
+		if len(droplets) == 0 {
+			report.Infof("No droplets.")
+		}
+		for _, d := range droplets {
+			DropletPrint(d)
+		}
+	})
+}
+
+func dropletsShow(ctx *cmd.Context) error {
+	spin := report.Step("fetching droplet")
+	d, err := sand.GetDroplet(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	DropletPrint(d)
-	return nil
+	return printResult(ctx, d, func() { DropletPrint(d) })
 }
 
-func dropletsNew(args []string) error {
-	fmt.Print("name: ")
+func dropletsNew(ctx *cmd.Context) error {
+	report.Prompt("name: ")
 	var name string
 	_, err := fmt.Scanln(&name)
 	if err != nil {
 		return err
 	}
-	err = sizes([]string{})
+	err = sizes(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Print("size id: ")
+	report.Prompt("size id: ")
 	var sizeId string
 	_, err = fmt.Scanln(&sizeId)
 	if err != nil {
 		return err
 	}
-	err = imagesList([]string{})
+	err = imagesList(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Print("image id: ")
+	report.Prompt("image id: ")
 	var imageId string
 	_, err = fmt.Scanln(&imageId)
 	if err != nil {
 		return err
 	}
-	err = regions([]string{})
+	err = regions(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Print("region id: ")
+	report.Prompt("region id: ")
 	var regionId string
 	_, err = fmt.Scanln(&regionId)
 	if err != nil {
 		return err
 	}
-	err = keysList([]string{})
+	err = keysList(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Print("key ids (comma separated): ")
+	report.Prompt("key ids (comma separated): ")
 	var keyIds string
 	_, err = fmt.Scanln(&keyIds)
 	if err != nil {
 		return err
 	}
-	fmt.Print("creating droplet... ")
+	spin := report.Step("creating droplet")
 	d, err := sand.CreateDroplet(name, sizeId, imageId, regionId, keyIds)
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	DropletCreationPrint(d)
-	return nil
+	return printResult(ctx, d, func() { DropletCreationPrint(d) })
 }
 
-func dropletsSSH(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching droplet... ")
-	d, err := sand.GetDroplet(args[0])
+func dropletsSSH(ctx *cmd.Context) error {
+	spin := report.Step("fetching droplet")
+	d, err := sand.GetDroplet(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	fmt.Println("running ssh...")
+	report.Infof("running ssh...")
 	command := exec.Command("ssh", "root@"+d.IPAddress)
 	command.Stdin = os.Stdin
 	command.Stdout = os.Stdout
@@ -206,35 +380,29 @@ func dropletsSSH(args []string) error {
 	return command.Run()
 }
 
-func dropletsSCP(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching droplet... ")
-	d, err := sand.GetDroplet(args[1])
+func dropletsSCP(ctx *cmd.Context) error {
+	spin := report.Step("fetching droplet")
+	d, err := sand.GetDroplet(ctx.Args[1])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	fmt.Println("running scp...")
-	command := exec.Command("scp", args[0], "root@"+d.IPAddress+":")
+	report.Infof("running scp...")
+	command := exec.Command("scp", ctx.Args[0], "root@"+d.IPAddress+":")
 	command.Stdin = os.Stdin
 	command.Stdout = os.Stdout
 	command.Stderr = os.Stderr
 	return command.Run()
 }
 
-func dropletsOpen(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching droplet... ")
-	d, err := sand.GetDroplet(args[0])
+func dropletsOpen(ctx *cmd.Context) error {
+	spin := report.Step("fetching droplet")
+	d, err := sand.GetDroplet(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	fmt.Println("opening...")
+	report.Infof("opening...")
 	command := exec.Command("open", "http://"+d.IPAddress)
 	command.Stdin = os.Stdin
 	command.Stdout = os.Stdout
@@ -242,464 +410,363 @@ func dropletsOpen(args []string) error {
 	return command.Run()
 }
 
-func dropletsShutdown(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing shutdown command... ")
-	e, err := sand.ShutdownDroplet(args[0])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsShutdown(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.ShutdownDroplet(ctx.Args[0])
 }
 
-func dropletsReboot(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing reboot command... ")
-	e, err := sand.RebootDroplet(args[0])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsReboot(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.RebootDroplet(ctx.Args[0])
 }
 
-func dropletsPoweroff(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing poweroff command... ")
-	e, err := sand.PoweroffDroplet(args[0])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsPoweroff(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.PoweroffDroplet(ctx.Args[0])
 }
 
-func dropletsPoweron(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing poweron command... ")
-	e, err := sand.PoweronDroplet(args[0])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsPoweron(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.PoweronDroplet(ctx.Args[0])
 }
 
-func dropletsPowercycle(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing powercycle command... ")
-	e, err := sand.PowercycleDroplet(args[0])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsPowercycle(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.PowercycleDroplet(ctx.Args[0])
 }
 
-func dropletsResize(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing resize command... ")
-	e, err := sand.ResizeDroplet(args[0], args[1])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsResize(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.ResizeDroplet(ctx.Args[0], ctx.Args[1])
 }
 
-func dropletsSnapshot(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing snapshot command... ")
-	e, err := sand.SnapshotDroplet(args[0], args[1])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsSnapshot(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.SnapshotDroplet(ctx.Args[0], ctx.Args[1])
 }
 
-func dropletsRestore(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing restore command... ")
-	e, err := sand.RestoreDroplet(args[0], args[1])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsRestore(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.RestoreDroplet(ctx.Args[0], ctx.Args[1])
 }
 
-func dropletsRebuild(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing rebuild command... ")
-	e, err := sand.RebuildDroplet(args[0], args[1])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsRebuild(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.RebuildDroplet(ctx.Args[0], ctx.Args[1])
 }
 
-func dropletsRename(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing rename command... ")
-	e, err := sand.RenameDroplet(args[0], args[1])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsRename(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.RenameDroplet(ctx.Args[0], ctx.Args[1])
 }
 
-func dropletsResetpass(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing resetpass command... ")
-	e, err := sand.ResetpassDroplet(args[0])
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsResetpass(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.ResetpassDroplet(ctx.Args[0])
 }
 
-func dropletsDestroy(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	scrub, err := strconv.ParseBool(args[1])
-	if err != nil {
-		return err
-	}
-	fmt.Print("issuing destroy command... ")
-	e, err := sand.DestroyDroplet(args[0], scrub)
-	if err != nil {
-		return err
-	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
-	return nil
+func dropletsDestroy(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.DestroyDroplet(ctx.Args[0], ctx.Bool("scrub"))
 }
 
-func domainsList(args []string) error {
-	if len(args) != 0 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching domains... ")
+func domainsList(ctx *cmd.Context) error {
+	spin := report.Step("fetching domains")
 	domains, err := sand.GetDomains()
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	for _, d := range domains {
-		DomainPrint(d)
-	}
-	return nil
+	writeCache("domains", domains)
+	return printResult(ctx, domains, func() {
+		for _, d := range domains {
+			DomainPrint(d)
+		}
+	})
 }
 
-func domainsShow(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching domain... ")
-	d, err := sand.GetDomain(args[0])
+func domainsShow(ctx *cmd.Context) error {
+	spin := report.Step("fetching domain")
+	d, err := sand.GetDomain(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	DomainPrint(d)
-	return nil
+	return printResult(ctx, d, func() { DomainPrint(d) })
 }
 
-func domainsNew(args []string) error {
+func domainsNew(ctx *cmd.Context) error {
 	return errors.New("not implemented")
 }
 
-func domainsDestroy(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("destroying the domain... ")
-	err := sand.DestroyDomain(args[0])
+func domainsDestroy(ctx *cmd.Context) error {
+	spin := report.Step("destroying the domain")
+	err := sand.DestroyDomain(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
 	return nil
 }
 
-func recordsList(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching records... ")
-	records, err := sand.GetRecords(args[0])
+func recordsList(ctx *cmd.Context) error {
+	spin := report.Step("fetching records")
+	records, err := sand.GetRecords(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	for _, r := range records {
-		RecordPrint(r)
-	}
-	return nil
+	writeCache("records-"+ctx.Args[0], records)
+	return printResult(ctx, records, func() {
+		for _, r := range records {
+			RecordPrint(r)
+		}
+	})
 }
 
-func recordsShow(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching record... ")
-	r, err := sand.GetRecord(args[0], args[1])
+func recordsShow(ctx *cmd.Context) error {
+	spin := report.Step("fetching record")
+	r, err := sand.GetRecord(ctx.Args[0], ctx.Args[1])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	RecordPrint(r)
-	return nil
+	return printResult(ctx, r, func() { RecordPrint(r) })
 }
 
-func recordsNew(args []string) error {
+func recordsNew(ctx *cmd.Context) error {
 	return errors.New("not implemented")
 }
 
-func recordsEdit(args []string) error {
+func recordsEdit(ctx *cmd.Context) error {
 	return errors.New("not implemented")
 }
 
-func recordsDestroy(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("destroying record... ")
-	err := sand.DestroyRecord(args[0], args[1])
+func recordsDestroy(ctx *cmd.Context) error {
+	spin := report.Step("destroying record")
+	err := sand.DestroyRecord(ctx.Args[0], ctx.Args[1])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
 	return nil
 }
 
-func keysList(args []string) error {
-	if len(args) != 0 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching ssh keys... ")
+func keysList(ctx *cmd.Context) error {
+	spin := report.Step("fetching ssh keys")
 	keys, err := sand.GetKeys()
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	for _, k := range keys {
-		KeyPrint(k)
-	}
-	return nil
+	writeCache("keys", keys)
+	return printResult(ctx, keys, func() {
+		for _, k := range keys {
+			KeyPrint(k)
+		}
+	})
 }
 
-func keysShow(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching ssh key... ")
-	k, err := sand.GetKey(args[0])
+func keysShow(ctx *cmd.Context) error {
+	spin := report.Step("fetching ssh key")
+	k, err := sand.GetKey(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	KeyPrint(k)
-	return nil
+	return printResult(ctx, k, func() { KeyPrint(k) })
 }
 
-func keysAdd(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("looking for local key... ")
+func keysAdd(ctx *cmd.Context) error {
+	spin := report.Step("looking for local key")
 	keyStr, err := readPublicKey()
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	fmt.Print("uploading key... ")
-	k, err := sand.AddKey(args[0], keyStr)
+	spin = report.Step("uploading key")
+	k, err := sand.AddKey(ctx.Args[0], keyStr)
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	KeyPrint(k)
-	return nil
+	return printResult(ctx, k, func() { KeyPrint(k) })
 }
 
-func keysUpdate(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("looking for local key... ")
+func keysUpdate(ctx *cmd.Context) error {
+	spin := report.Step("looking for local key")
 	keyStr, err := readPublicKey()
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	fmt.Print("updating remote key to match... ")
-	k, err := sand.UpdateKey(args[0], keyStr)
+	spin = report.Step("updating remote key to match")
+	k, err := sand.UpdateKey(ctx.Args[0], keyStr)
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	KeyPrint(k)
-	return nil
+	return printResult(ctx, k, func() { KeyPrint(k) })
 }
 
-func keysDelete(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("deleting key... ")
-	err := sand.DeleteKey(args[0])
+func keysDelete(ctx *cmd.Context) error {
+	spin := report.Step("deleting key")
+	err := sand.DeleteKey(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
 	return nil
 }
 
-func imagesList(args []string) error {
-	if len(args) != 0 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching images... ")
-	images, err := sand.GetImages()
+func imagesList(ctx *cmd.Context) error {
+	spin := report.Step("fetching images")
+	var images []*sand.Image
+	err := referenceData("images", &images, func() (interface{}, error) { return sand.GetImages() })
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	for _, image := range images {
-		ImagePrint(image)
-	}
-	return nil
+	return printResult(ctx, images, func() {
+		for _, image := range images {
+			ImagePrint(image)
+		}
+	})
 }
 
-func imagesShow(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching image... ")
-	image, err := sand.GetImage(args[0])
+func imagesShow(ctx *cmd.Context) error {
+	spin := report.Step("fetching image")
+	image, err := sand.GetImage(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	ImagePrint(image)
-	return nil
+	return printResult(ctx, image, func() { ImagePrint(image) })
 }
 
-func imagesTransfer(args []string) error {
-	if len(args) != 2 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("issuing transfer command... ")
-	e, err := sand.TransferImage(args[0], args[1])
+func imagesTransfer(ctx *cmd.Context) (*sand.EventId, error) {
+	return sand.TransferImage(ctx.Args[0], ctx.Args[1])
+}
+
+func imagesDestroy(ctx *cmd.Context) error {
+	spin := report.Step("destroying image")
+	err := sand.DestroyImage(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	EventIdPrint(e)
 	return nil
 }
 
-func imagesDestroy(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("destroying image... ")
-	err := sand.DestroyImage(args[0])
+func regions(ctx *cmd.Context) error {
+	spin := report.Step("fetching regions")
+	var regions []*sand.Region
+	err := referenceData("regions", &regions, func() (interface{}, error) { return sand.GetRegions() })
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	return nil
+	return printResult(ctx, regions, func() {
+		for _, r := range regions {
+			RegionPrint(r)
+		}
+	})
 }
 
-func regions(args []string) error {
-	if len(args) != 0 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching regions... ")
-	regions, err := sand.GetRegions()
+func sizes(ctx *cmd.Context) error {
+	spin := report.Step("fetching sizes")
+	var sizes []*sand.Size
+	err := referenceData("sizes", &sizes, func() (interface{}, error) { return sand.GetSizes() })
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	for _, r := range regions {
-		RegionPrint(r)
-	}
-	return nil
+	return printResult(ctx, sizes, func() {
+		for _, s := range sizes {
+			SizePrint(s)
+		}
+	})
 }
 
-func sizes(args []string) error {
-	if len(args) != 0 {
-		return cmd.ErrInvalidArgs
-	}
-	fmt.Print("fetching sizes... ")
-	sizes, err := sand.GetSizes()
+func event(ctx *cmd.Context) error {
+	spin := report.Step("fetching event status")
+	e, err := sand.GetEvent(ctx.Args[0])
+	spin.Stop(err)
 	if err != nil {
 		return err
 	}
-	fancy.Println(fancy.Green, "OK")
-	for _, s := range sizes {
-		SizePrint(s)
-	}
-	return nil
+	return printResult(ctx, e, func() { EventPrint(e) })
 }
 
-func event(args []string) error {
-	if len(args) != 1 {
-		return cmd.ErrInvalidArgs
+// pluginEnv passes the resolved credentials to plugin commands.
+func pluginEnv(ctx *cmd.Context) ([]string, error) {
+	if err := selectProfile(ctx.String("profile")); err != nil {
+		return nil, err
 	}
-	fmt.Print("fetching event status... ")
-	e, err := sand.GetEvent(args[0])
+	key, err := sand.ResolveApiKey()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fancy.Println(fancy.Green, "OK")
-	EventPrint(e)
-	return nil
+	env := []string{
+		"FAUCET_CLIENT_ID=" + sand.ClientId,
+		"FAUCET_API_KEY=" + key,
+		"FAUCET_API_URL=" + sand.BaseURL,
+		"FAUCET_PROFILE=" + activeProfile,
+	}
+	// Only a file given with --config needs passing on: a faucet run by the
+	// plugin finds the others itself.
+	if path := ctx.String("config"); path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		env = append(env, "FAUCET_CONFIG="+path)
+	}
+	return env, nil
 }
 
-func help(args []string) error {
-	return errors.New("not implemented")
+// eventCommand adds a command whose action starts an event. It prints the
+// event id and, with --wait, follows the event until it is done.
+func eventCommand(parent *cmd.Node, name, description, usage string, fn func(*cmd.Context) (*sand.EventId, error)) *cmd.Node {
+	return parent.Command(name, description, usage, func(ctx *cmd.Context) error {
+		spin := report.Step("issuing " + name + " command")
+		e, err := fn(ctx)
+		spin.Stop(err)
+		if err != nil {
+			return err
+		}
+		err = printResult(ctx, map[string]int{"event_id": int(*e)}, func() { EventIdPrint(e) })
+		if err != nil || !ctx.Bool("wait") {
+			return err
+		}
+		return waitEvent(ctx, strconv.Itoa(int(*e)))
+	}).Bool("wait", "w", false, "wait for the event to finish")
+}
+
+func wait(ctx *cmd.Context) error {
+	return waitEvent(ctx, ctx.Args[0])
+}
+
+// waitEvent polls the event until it is done, or until --timeout has
+// passed.
+func waitEvent(ctx *cmd.Context, id string) error {
+	bar := report.Progress("waiting for event " + id)
+	var deadline time.Time
+	if timeout := ctx.Duration("timeout"); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			err := fmt.Errorf("waiting for event %s: %w", id, context.DeadlineExceeded)
+			bar.Stop(err)
+			return err
+		}
+		e, err := sand.GetEvent(id)
+		if err != nil {
+			bar.Stop(err)
+			return err
+		}
+		if e.Status == "done" {
+			bar.Set(100)
+			bar.Stop(nil)
+			return nil
+		}
+		percent, _ := strconv.ParseFloat(e.Percentage, 64)
+		bar.Set(int(percent))
+		select {
+		case <-time.After(eventPollInterval):
+		case <-sand.Context.Done():
+			bar.Stop(sand.Context.Err())
+			return sand.Context.Err()
+		}
+	}
 }
 
 func readPublicKey() (string, error) {
@@ -708,7 +775,7 @@ func readPublicKey() (string, error) {
 }
 
 func DropletPrint(d *sand.Droplet) {
-	fancy.Print(fancy.Blue, d.Name)
+	fancy.Print(fancy.Current.Name, d.Name)
 	fmt.Printf(` {
   Id: %d
   ImageId: %d
@@ -726,7 +793,7 @@ func DropletPrint(d *sand.Droplet) {
 }
 
 func DropletCreationPrint(d *sand.DropletCreation) {
-	fancy.Print(fancy.Blue, d.Name)
+	fancy.Print(fancy.Current.Name, d.Name)
 	fmt.Printf(` {
   Id: %d
   ImageId: %d
@@ -737,7 +804,7 @@ func DropletCreationPrint(d *sand.DropletCreation) {
 }
 
 func DomainPrint(d *sand.Domain) {
-	fancy.Print(fancy.Blue, d.Name)
+	fancy.Print(fancy.Current.Name, d.Name)
 	fmt.Printf(` {
   Id: %d
   TTL: %d
@@ -751,7 +818,7 @@ func DomainPrint(d *sand.Domain) {
 }
 
 func RecordPrint(r *sand.Record) {
-	fancy.Print(fancy.Blue, r.Name)
+	fancy.Print(fancy.Current.Name, r.Name)
 	fmt.Printf(` {
   Id: %d
   DomainId: %d
@@ -766,11 +833,11 @@ func RecordPrint(r *sand.Record) {
 
 func EventIdPrint(e *sand.EventId) {
 	fmt.Print("Event Id: ")
-	fancy.Println(fancy.Blue, int(*e))
+	fancy.Println(fancy.Current.Name, int(*e))
 }
 
 func EventPrint(e *sand.Event) {
-	fancy.Println(fancy.Blue, e.Id)
+	fancy.Println(fancy.Current.Name, e.Id)
 	fmt.Printf(` {
   Status: %s
   DropletId: %d
@@ -781,7 +848,7 @@ func EventPrint(e *sand.Event) {
 }
 
 func KeyPrint(k *sand.Key) {
-	fancy.Print(fancy.Blue, k.Name)
+	fancy.Print(fancy.Current.Name, k.Name)
 	if k.PublicKey != "" {
 		fmt.Printf(` {
   Id: %d
@@ -797,7 +864,7 @@ func KeyPrint(k *sand.Key) {
 }
 
 func ImagePrint(i *sand.Image) {
-	fancy.Print(fancy.Blue, i.Name)
+	fancy.Print(fancy.Current.Name, i.Name)
 	fmt.Printf(` {
   Id: %d
   Distribution: %s
@@ -806,7 +873,7 @@ func ImagePrint(i *sand.Image) {
 }
 
 func RegionPrint(r *sand.Region) {
-	fancy.Print(fancy.Blue, r.Name)
+	fancy.Print(fancy.Current.Name, r.Name)
 	fmt.Printf(` {
   Id: %d
 }
@@ -814,7 +881,7 @@ func RegionPrint(r *sand.Region) {
 }
 
 func SizePrint(s *sand.Size) {
-	fancy.Print(fancy.Blue, s.Name)
+	fancy.Print(fancy.Current.Name, s.Name)
 	fmt.Printf(` {
   Id: %d
 }
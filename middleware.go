@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/fancy"
+	"github.com/whub/faucet/sand"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+var errAborted = errors.New("aborted")
+
+// globals applies the persistent root flags before the command runs.
+func globals(next cmd.CmdFunc) cmd.CmdFunc {
+	return func(ctx *cmd.Context) error {
+		switch output := ctx.String("output"); output {
+		case "text", "json":
+		default:
+			return &cmd.UsageError{Command: ctx.Node.Path(), Err: fmt.Errorf("unknown output format: %s", output)}
+		}
+		switch {
+		case ctx.Bool("debug"):
+			report.level = Verbose
+		case ctx.Bool("quiet"):
+			report.level = Quiet
+		case ctx.Bool("verbose"):
+			report.level = Verbose
+		default:
+			report.level = Normal
+		}
+		sand.Client.Timeout = apiTimeout
+		if timeout := ctx.Duration("timeout"); timeout > 0 {
+			sand.Client.Timeout = timeout
+		}
+		sand.Client.Transport = lazyTransport{}
+		if ctx.Bool("debug") {
+			sand.Client.Transport = &sand.DebugTransport{Transport: lazyTransport{}, Log: report.w}
+		}
+		if err := selectProfile(ctx.String("profile")); err != nil {
+			// Left for the first request to report, so that commands that
+			// make none, such as help and profile use, can still put it
+			// right.
+			activeProfile = ""
+			sand.ClientId, sand.ApiKey = "", ""
+			sand.ApiKeyFunc = func() (string, error) { return "", err }
+		}
+		return next(ctx)
+	}
+}
+
+// timing reports how long each command took in verbose mode.
+func timing(next cmd.CmdFunc) cmd.CmdFunc {
+	return func(ctx *cmd.Context) error {
+		start := time.Now()
+		err := next(ctx)
+		report.Debugf("%s took %s", ctx.Node.Path(), time.Since(start).Round(time.Millisecond))
+		return err
+	}
+}
+
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// audit appends a JSON line per command to the file named by auditLog in
+// the config, if any. Hidden commands, such as the one shell completion
+// calls, are not logged.
+func audit(next cmd.CmdFunc) cmd.CmdFunc {
+	return func(ctx *cmd.Context) error {
+		if config.AuditLog == "" || ctx.Node.Hidden {
+			return next(ctx)
+		}
+		start := time.Now()
+		err := next(ctx)
+		entry := auditEntry{
+			Time:     start,
+			Command:  ctx.Node.Path(),
+			Args:     ctx.Args,
+			Duration: time.Since(start).String(),
+		}
+		if u, uerr := user.Current(); uerr == nil {
+			entry.User = u.Username
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if werr := appendAudit(entry); werr != nil {
+			report.Infof("could not write audit log: %v", werr)
+		}
+		return err
+	}
+}
+
+func appendAudit(entry auditEntry) error {
+	f, err := os.OpenFile(config.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(entry)
+}
+
+// confirm asks before running destructive commands, unless --yes is given.
+// Without a terminal to ask on, the command is refused rather than run
+// unconfirmed.
+func confirm(next cmd.CmdFunc) cmd.CmdFunc {
+	return func(ctx *cmd.Context) error {
+		n := ctx.Node
+		if !n.Destructive || ctx.Bool("yes") {
+			return next(ctx)
+		}
+		if !fancy.IsTerminal(os.Stdin) {
+			return &cmd.UsageError{Command: n.Path(), Err: errors.New("refusing to run without a terminal to confirm on; pass --yes")}
+		}
+		report.Prompt(fmt.Sprintf("%s %s: are you sure? [y/N] ", n.Path(), strings.Join(ctx.Args, " ")))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return next(ctx)
+		}
+		return fmt.Errorf("%s: %v", n.Path(), errAborted)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"github.com/whub/faucet/cmd"
+)
+
+// checkPerms warns when a config file holding a plaintext api key is open
+// to other users. In strict mode it returns an error instead.
+func checkPerms(path string, m map[string]interface{}, strict bool) error {
+	if !hasPlainApiKey(m) {
+		return nil
+	}
+	problem := insecurePerms(path)
+	if problem == "" {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("refusing to read %s: it holds an api key and %s; run faucet config fix-perms", path, problem)
+	}
+	report.Infof("warning: %s holds an api key and %s; run faucet config fix-perms", path, problem)
+	return nil
+}
+
+func hasPlainApiKey(m map[string]interface{}) bool {
+	if key, ok := m["apiKey"].(string); ok && key != "" && !isEncrypted(key) {
+		return true
+	}
+	profiles, _ := m["profiles"].(map[string]interface{})
+	for _, p := range profiles {
+		p, _ := p.(map[string]interface{})
+		if key, ok := p["apiKey"].(string); ok && key != "" && !isEncrypted(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func configFixPerms(ctx *cmd.Context) error {
+	fixed := 0
+	for _, layer := range configLayers(ctx.String("config")) {
+		ok, err := fixPerms(layer.path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			report.Infof("made %s readable only by you", layer.path)
+			fixed++
+		}
+	}
+	if fixed == 0 {
+		report.Infof("nothing to fix")
+	}
+	return nil
+}
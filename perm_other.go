@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+// Permission bits mean little outside unix, so there is nothing to check.
+
+func insecurePerms(path string) string {
+	return ""
+}
+
+func fixPerms(path string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// insecurePerms describes what is wrong with the permissions of path, or
+// returns "" if nothing is.
+func insecurePerms(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() && st.Uid != 0 {
+		return fmt.Sprintf("is owned by another user (uid %d)", st.Uid)
+	}
+	if mode := fi.Mode().Perm(); mode&0066 != 0 {
+		return fmt.Sprintf("is open to group or others (mode %04o)", mode)
+	}
+	return ""
+}
+
+// fixPerms makes path readable and writable only by its owner, if it is
+// ours and is not already. It reports whether it changed anything.
+func fixPerms(path string) (bool, error) {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if fi.Mode().Perm()&0066 == 0 {
+		return false, nil
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		report.Infof("skipping %s: owned by uid %d", path, st.Uid)
+		return false, nil
+	}
+	return true, os.Chmod(path, fi.Mode().Perm()&^0077)
+}
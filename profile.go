@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/sand"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Profile holds the credentials for one account.
+type Profile struct {
+	ClientId      string `json:"clientId"`
+	ApiKey        string `json:"apiKey,omitempty"`
+	ApiKeyCommand string `json:"apiKeyCommand,omitempty"`
+}
+
+// apiKeys caches the output of each apiKeyCommand for the life of the
+// process, so a shell or batch only runs a helper once.
+var apiKeys = make(map[string]string)
+
+// apiKeyHelper returns a function that runs command with sh, in the manner
+// of a git credential helper, and takes the api key from the first line it
+// prints. The helper can prompt on the terminal, since it shares stdin and
+// stderr.
+func apiKeyHelper(command string) func() (string, error) {
+	return func() (string, error) {
+		if key, ok := apiKeys[command]; ok {
+			return key, nil
+		}
+		// The helper may well prompt, which completion cannot allow.
+		if completing {
+			return "", errWouldPrompt
+		}
+		c := exec.Command("sh", "-c", command)
+		c.Stdin = os.Stdin
+		c.Stderr = os.Stderr
+		out, err := c.Output()
+		if err != nil {
+			// Not wrapped, so the helper's exit status is not taken for
+			// faucet's own.
+			return "", fmt.Errorf("apiKeyCommand %q failed: %v", command, err)
+		}
+		key := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+		if key == "" {
+			return "", fmt.Errorf("apiKeyCommand %q printed no api key", command)
+		}
+		apiKeys[command] = key
+		return key, nil
+	}
+}
+
+// activeProfile is the name of the profile in use, or "" for the
+// credentials at the top level of the config.
+var activeProfile string
+
+// clientIdFrom and apiKeyFrom say where the credentials in use were set: a
+// config file or an environment variable, and for the api key how it is
+// got if it is not there in plain text.
+var clientIdFrom, apiKeyFrom string
+
+// selectProfile points sand at the credentials of the profile named by
+// --profile, then $FAUCET_PROFILE, then defaultProfile in the config. A
+// default profile from a config file gives way to credentials in the
+// environment, which are specific to this run: when both are there the
+// profile is not used, and otherwise the one that is there is laid over it.
+func selectProfile(flag string) error {
+	name := flag
+	if name == "" {
+		name = os.Getenv("FAUCET_PROFILE")
+	}
+	fileDefault := false
+	if name == "" {
+		name = config.DefaultProfile
+		fileDefault = !setByEnv("defaultProfile")
+	}
+	envKey := setByEnv("apiKey") || setByEnv("apiKeyCommand")
+	if fileDefault && setByEnv("clientId") && envKey {
+		name = ""
+	}
+	p := Profile{config.ClientId, config.ApiKey, config.ApiKeyCommand}
+	prefix := ""
+	if name != "" {
+		var ok bool
+		if p, ok = config.Profiles[name]; !ok {
+			return fmt.Errorf("no such profile: %s", name)
+		}
+		prefix = "profiles." + name + "."
+	} else if setByEnv("apiKeyCommand") && !setByEnv("apiKey") {
+		// The environment wins over the files even though a plain apiKey
+		// would win over an apiKeyCommand in the same place.
+		p.ApiKey = ""
+	}
+	clientIdFrom = credentialOrigin(prefix + "clientId")
+	apiKeyFrom = credentialOrigin(prefix + "apiKey")
+	if p.ApiKey == "" && p.ApiKeyCommand != "" {
+		apiKeyFrom = credentialOrigin(prefix + "apiKeyCommand")
+	}
+	if name != "" && fileDefault {
+		if setByEnv("clientId") {
+			p.ClientId, clientIdFrom = config.ClientId, credentialOrigin("clientId")
+		}
+		switch {
+		case setByEnv("apiKey"):
+			p.ApiKey, apiKeyFrom = config.ApiKey, credentialOrigin("apiKey")
+		case setByEnv("apiKeyCommand"):
+			p.ApiKey, p.ApiKeyCommand = "", config.ApiKeyCommand
+			apiKeyFrom = credentialOrigin("apiKeyCommand")
+		}
+	}
+	activeProfile = name
+	sand.ClientId = p.ClientId
+	sand.ApiKey = p.ApiKey
+	sand.ApiKeyFunc = nil
+	switch {
+	case isEncrypted(p.ApiKey):
+		sand.ApiKey = ""
+		sand.ApiKeyFunc = decryptHelper(p.ApiKey)
+		apiKeyFrom = "encrypted with a passphrase in " + apiKeyFrom
+	case p.ApiKey == "" && p.ApiKeyCommand != "":
+		sand.ApiKeyFunc = apiKeyHelper(p.ApiKeyCommand)
+		apiKeyFrom = "from apiKeyCommand in " + apiKeyFrom
+	}
+	return nil
+}
+
+func profileList(ctx *cmd.Context) error {
+	if len(config.Profiles) == 0 {
+		report.Infof("No profiles.")
+		return nil
+	}
+	var names []string
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		var marks string
+		if name == activeProfile {
+			marks = "*"
+		}
+		if name == config.DefaultProfile {
+			marks += " (default)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, config.Profiles[name].ClientId, marks)
+	}
+	return w.Flush()
+}
+
+func profileUse(ctx *cmd.Context) error {
+	name := ctx.Args[0]
+	if _, ok := config.Profiles[name]; !ok {
+		return fmt.Errorf("no such profile: %s", name)
+	}
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	err = updateConfig(path, func(c *Config) error {
+		c.DefaultProfile = name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if config.DefaultProfile != "" && config.DefaultProfile != name {
+		if origin := configOrigin("defaultProfile"); overrides(origin, path, ctx.String("config")) {
+			report.Infof("note: %s sets defaultProfile to %s and takes precedence", origin, config.DefaultProfile)
+		}
+	}
+	return nil
+}
+
+func profileAdd(ctx *cmd.Context) error {
+	name := ctx.Args[0]
+	var p Profile
+	var err error
+	if p.ClientId, err = promptLine("client id: ", false); err != nil {
+		return err
+	}
+	if p.ApiKey, err = promptLine("api key: ", true); err != nil {
+		return err
+	}
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	return updateConfig(path, func(c *Config) error {
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]Profile)
+		}
+		c.Profiles[name] = p
+		if config.ClientId == "" && config.DefaultProfile == "" {
+			c.DefaultProfile = name
+		}
+		return nil
+	})
+}
+
+func profileRemove(ctx *cmd.Context) error {
+	name := ctx.Args[0]
+	err := updateConfig(configOrigin("profiles."+name), func(c *Config) error {
+		if _, ok := c.Profiles[name]; !ok {
+			return fmt.Errorf("no such profile: %s", name)
+		}
+		delete(c.Profiles, name)
+		if c.DefaultProfile == name {
+			c.DefaultProfile = ""
+		}
+		return nil
+	})
+	if err != nil || config.DefaultProfile != name {
+		return err
+	}
+	// The default may be set in another file than the profile, and would
+	// be left naming a profile that is gone.
+	origin := configOrigin("defaultProfile")
+	if strings.HasPrefix(origin, "$") {
+		report.Infof("note: %s still names the removed profile", origin)
+		return nil
+	}
+	return updateConfig(origin, func(c *Config) error {
+		if c.DefaultProfile == name {
+			c.DefaultProfile = ""
+		}
+		return nil
+	})
+}
+
+func completeProfiles(args []string) []string {
+	var candidates []string
+	for name, p := range config.Profiles {
+		candidates = append(candidates, name+"\t"+p.ClientId)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/whub/faucet/sand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadTestConfig loads user and project, the contents of a user config
+// file and a faucet.json, and the file named by configFlag, with the
+// environment cleared but for env, and selects the profile named by
+// profileFlag.
+func loadTestConfig(t *testing.T, user, project string, env map[string]string, configFlag, profileFlag string) error {
+	t.Helper()
+	home, dir := t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	for _, e := range configEnv {
+		t.Setenv(e.name, "")
+	}
+	for _, name := range []string{"FAUCET_CONFIG", "FAUCET_PROFILE"} {
+		t.Setenv(name, "")
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	if user != "" {
+		os.MkdirAll(filepath.Join(home, "faucet"), 0700)
+		if err := ioutil.WriteFile(filepath.Join(home, "faucet", "config.json"), []byte(user), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if project != "" {
+		if err := ioutil.WriteFile(filepath.Join(dir, "faucet.json"), []byte(project), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	config, configPath, configOrigins = Config{}, "", make(map[string]string)
+	loadConfig(configFlag, false)
+	return selectProfile(profileFlag)
+}
+
+func TestApiKeyCommandFromEnv(t *testing.T) {
+	tests := []struct {
+		user    string
+		env     map[string]string
+		command bool
+		key     string
+	}{
+		{`{"clientId": "c", "apiKey": "filekey"}`, nil, false, "filekey"},
+		{`{"clientId": "c", "apiKey": "filekey"}`, map[string]string{"FAUCET_API_KEY_COMMAND": "echo k"}, true, ""},
+		{`{"clientId": "c", "apiKeyCommand": "echo k"}`, map[string]string{"FAUCET_API_KEY": "envkey"}, false, "envkey"},
+		{`{"clientId": "c"}`, map[string]string{"FAUCET_API_KEY": "envkey", "FAUCET_API_KEY_COMMAND": "echo k"}, false, "envkey"},
+		{`{"clientId": "c", "apiKey": "filekey", "apiKeyCommand": "echo k"}`, nil, false, "filekey"},
+	}
+	for _, tt := range tests {
+		if err := loadTestConfig(t, tt.user, "", tt.env, "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if got := sand.ApiKeyFunc != nil; got != tt.command {
+			t.Errorf("%s with %v: apiKeyCommand used = %v, want %v", tt.user, tt.env, got, tt.command)
+		}
+		if got := sand.ApiKey; got != tt.key {
+			t.Errorf("%s with %v: api key = %q, want %q", tt.user, tt.env, got, tt.key)
+		}
+	}
+}
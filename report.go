@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"github.com/whub/faucet/fancy"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+type Verbosity int
+
+const (
+	Quiet Verbosity = iota
+	Normal
+	Verbose
+)
+
+// Reporter writes progress chatter (spinners, bars and notes) to stderr so
+// that stdout only carries command results.
+type Reporter struct {
+	w     io.Writer
+	level Verbosity
+}
+
+func NewReporter(w io.Writer, level Verbosity) *Reporter {
+	return &Reporter{w: w, level: level}
+}
+
+var report = NewReporter(os.Stderr, Normal)
+
+func (r *Reporter) writer(level Verbosity) io.Writer {
+	if r.level < level {
+		return ioutil.Discard
+	}
+	return r.w
+}
+
+func (r *Reporter) Step(msg string) *fancy.Spinner {
+	return fancy.StartSpinner(r.writer(Normal), msg)
+}
+
+func (r *Reporter) Progress(msg string) *fancy.Bar {
+	return fancy.NewBar(r.writer(Normal), msg)
+}
+
+func (r *Reporter) Infof(format string, a ...interface{}) {
+	fmt.Fprintf(r.writer(Normal), format+"\n", a...)
+}
+
+func (r *Reporter) Debugf(format string, a ...interface{}) {
+	fmt.Fprintf(r.writer(Verbose), format+"\n", a...)
+}
+
+func (r *Reporter) Prompt(msg string) {
+	fmt.Fprint(r.w, msg)
+}
+
+func (r *Reporter) Error(err error) {
+	fancy.Fprintln(r.w, fancy.Current.Error, err)
+}
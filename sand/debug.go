@@ -0,0 +1,113 @@
+package sand
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDebugBody caps how much of a response body DebugTransport logs.
+const maxDebugBody = 64 << 10
+
+// Query parameters and headers whose values are never logged.
+var (
+	secretParams  = []string{"api_key", "client_id", "token", "access_token", "password", "secret"}
+	secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+)
+
+// DebugTransport is an http.RoundTripper that logs the method, URL,
+// headers, status, latency and response body of every request to Log, with
+// credentials redacted.
+type DebugTransport struct {
+	Transport http.RoundTripper // http.DefaultTransport if nil
+	Log       io.Writer
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	fmt.Fprintf(t.Log, "--> %s %s\n", req.Method, RedactURL(req.URL.String()))
+	logHeaders(t.Log, req.Header)
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(t.Log, "<-- error after %s: %s\n", latency, Redact(err.Error()))
+		return nil, err
+	}
+	fmt.Fprintf(t.Log, "<-- %s (%s)\n", resp.Status, latency)
+	logHeaders(t.Log, resp.Header)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	logged := body
+	if len(logged) > maxDebugBody {
+		logged = logged[:maxDebugBody]
+	}
+	fmt.Fprintf(t.Log, "%s\n", Redact(string(logged)))
+	if len(logged) < len(body) {
+		fmt.Fprintf(t.Log, "... %d more bytes\n", len(body)-len(logged))
+	}
+	return resp, nil
+}
+
+func logHeaders(w io.Writer, h http.Header) {
+	var names []string
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(h[name], ", ")
+		for _, secret := range secretHeaders {
+			if strings.EqualFold(name, secret) {
+				value = "REDACTED"
+			}
+		}
+		fmt.Fprintf(w, "    %s: %s\n", name, Redact(value))
+	}
+}
+
+// RedactURL replaces the values of credential query parameters in s, and
+// any password in it, with REDACTED. s is returned as is if it does not
+// parse.
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Redact(s)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	q := u.Query()
+	for _, p := range secretParams {
+		if q.Get(p) != "" {
+			q.Set(p, "REDACTED")
+		}
+	}
+	u.RawQuery = q.Encode()
+	return Redact(u.String())
+}
+
+// Redact replaces the api key wherever it appears in s, as is or escaped
+// for a query or a path. Keys too short to be real are left alone rather
+// than mangle unrelated text.
+func Redact(s string) string {
+	if len(ApiKey) < 8 {
+		return s
+	}
+	s = strings.Replace(s, ApiKey, "REDACTED", -1)
+	s = strings.Replace(s, url.QueryEscape(ApiKey), "REDACTED", -1)
+	return strings.Replace(s, url.PathEscape(ApiKey), "REDACTED", -1)
+}
@@ -1,19 +1,49 @@
 package sand
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var (
 	ClientId = ""
 	ApiKey   = ""
+	BaseURL  = "https://api.digitalocean.com"
+	Client   = &http.Client{}
 )
 
+// ApiKeyFunc, if set, is called for the api key the first time a request
+// needs one while ApiKey is empty.
+var ApiKeyFunc func() (string, error)
+
+// Context governs every request: cancelling it abandons the requests in
+// flight and any wait before a retry.
+var Context = context.Background()
+
+// ErrNoCredentials is returned instead of making a request when ClientId
+// or ApiKey is empty.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ResolveApiKey returns ApiKey, filling it in from ApiKeyFunc first if need be.
+func ResolveApiKey() (string, error) {
+	if ApiKey == "" && ApiKeyFunc != nil {
+		key, err := ApiKeyFunc()
+		if err != nil {
+			return "", err
+		}
+		ApiKey = key
+	}
+	return ApiKey, nil
+}
+
 type Droplet struct {
 	Id               int       `json:"id"`
 	Name             string    `json:"name"`
@@ -88,6 +118,28 @@ type Event struct {
 	Percentage string `json:"percentage"`
 }
 
+// APIError is returned when the API answers with a non-OK status.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+func (e *APIError) Unauthorized() bool {
+	msg := strings.ToLower(e.Message)
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+		strings.Contains(msg, "access denied") || strings.Contains(msg, "invalid api key") ||
+		strings.Contains(msg, "invalid client id")
+}
+
+func (e *APIError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound || strings.Contains(strings.ToLower(e.Message), "not found")
+}
+
 type Response interface {
 	GetStatus() string
 	GetMessage() string
@@ -256,37 +308,37 @@ func CreateDroplet(name, sizeId, imageId, regionId, keyIds string) (*DropletCrea
 	q.Set("image_id", imageId)
 	q.Set("region_id", regionId)
 	q.Set("ssh_key_ids", keyIds)
-	err := get(fmt.Sprintf("/droplets/new"), q, r)
+	err := act(fmt.Sprintf("/droplets/new"), q, r)
 	return r.DropletCreation, err
 }
 
 func ShutdownDroplet(id string) (*EventId, error) {
 	r := &EventIdResponse{}
-	err := get(fmt.Sprintf("/droplets/%s/shutdown/", id), url.Values{}, r)
+	err := act(fmt.Sprintf("/droplets/%s/shutdown/", id), url.Values{}, r)
 	return r.EventId, err
 }
 
 func RebootDroplet(id string) (*EventId, error) {
 	r := &EventIdResponse{}
-	err := get(fmt.Sprintf("/droplets/%s/reboot/", id), url.Values{}, r)
+	err := act(fmt.Sprintf("/droplets/%s/reboot/", id), url.Values{}, r)
 	return r.EventId, err
 }
 
 func PoweroffDroplet(id string) (*EventId, error) {
 	r := &EventIdResponse{}
-	err := get(fmt.Sprintf("/droplets/%s/power_off/", id), url.Values{}, r)
+	err := act(fmt.Sprintf("/droplets/%s/power_off/", id), url.Values{}, r)
 	return r.EventId, err
 }
 
 func PoweronDroplet(id string) (*EventId, error) {
 	r := &EventIdResponse{}
-	err := get(fmt.Sprintf("/droplets/%s/power_on/", id), url.Values{}, r)
+	err := act(fmt.Sprintf("/droplets/%s/power_on/", id), url.Values{}, r)
 	return r.EventId, err
 }
 
 func PowercycleDroplet(id string) (*EventId, error) {
 	r := &EventIdResponse{}
-	err := get(fmt.Sprintf("/droplets/%s/power_cycle/", id), url.Values{}, r)
+	err := act(fmt.Sprintf("/droplets/%s/power_cycle/", id), url.Values{}, r)
 	return r.EventId, err
 }
 
@@ -294,7 +346,7 @@ func ResizeDroplet(dropletId, sizeId string) (*EventId, error) {
 	r := &EventIdResponse{}
 	q := url.Values{}
 	q.Set("size_id", sizeId)
-	err := get(fmt.Sprintf("/droplets/%s/resize/", dropletId), q, r)
+	err := act(fmt.Sprintf("/droplets/%s/resize/", dropletId), q, r)
 	return r.EventId, err
 }
 
@@ -302,7 +354,7 @@ func SnapshotDroplet(id, name string) (*EventId, error) {
 	r := &EventIdResponse{}
 	q := url.Values{}
 	q.Set("name", name)
-	err := get(fmt.Sprintf("/droplets/%s/snapshot/", id), q, r)
+	err := act(fmt.Sprintf("/droplets/%s/snapshot/", id), q, r)
 	return r.EventId, err
 }
 
@@ -310,7 +362,7 @@ func RestoreDroplet(dropletId, imageId string) (*EventId, error) {
 	r := &EventIdResponse{}
 	q := url.Values{}
 	q.Set("image_id", imageId)
-	err := get(fmt.Sprintf("/droplets/%s/restore/", dropletId), q, r)
+	err := act(fmt.Sprintf("/droplets/%s/restore/", dropletId), q, r)
 	return r.EventId, err
 }
 
@@ -318,7 +370,7 @@ func RebuildDroplet(dropletId, imageId string) (*EventId, error) {
 	r := &EventIdResponse{}
 	q := url.Values{}
 	q.Set("image_id", imageId)
-	err := get(fmt.Sprintf("/droplets/%s/rebuild/", dropletId), q, r)
+	err := act(fmt.Sprintf("/droplets/%s/rebuild/", dropletId), q, r)
 	return r.EventId, err
 }
 
@@ -326,19 +378,23 @@ func RenameDroplet(id, name string) (*EventId, error) {
 	r := &EventIdResponse{}
 	q := url.Values{}
 	q.Set("name", name)
-	err := get(fmt.Sprintf("/droplets/%s/rename/", id), q, r)
+	err := act(fmt.Sprintf("/droplets/%s/rename/", id), q, r)
 	return r.EventId, err
 }
 
 func ResetpassDroplet(id string) (*EventId, error) {
 	r := &EventIdResponse{}
-	err := get(fmt.Sprintf("/droplets/%s/password_reset/", id), url.Values{}, r)
+	err := act(fmt.Sprintf("/droplets/%s/password_reset/", id), url.Values{}, r)
 	return r.EventId, err
 }
 
 func DestroyDroplet(id string, scrub bool) (*EventId, error) {
 	r := &EventIdResponse{}
-	err := get(fmt.Sprintf("/droplets/%s/destroy/", id), url.Values{}, r)
+	q := url.Values{}
+	if scrub {
+		q.Set("scrub_data", "true")
+	}
+	err := act(fmt.Sprintf("/droplets/%s/destroy/", id), q, r)
 	return r.EventId, err
 }
 
@@ -355,7 +411,7 @@ func GetDomain(id string) (*Domain, error) {
 }
 
 func DestroyDomain(id string) error {
-	return get(fmt.Sprintf("/domains/%s/destroy/", id), url.Values{}, &StatusResponse{})
+	return act(fmt.Sprintf("/domains/%s/destroy/", id), url.Values{}, &StatusResponse{})
 }
 
 func GetRecords(domainId string) ([]*Record, error) {
@@ -371,7 +427,7 @@ func GetRecord(domainId, recordId string) (*Record, error) {
 }
 
 func DestroyRecord(domainId, recordId string) error {
-	return get(fmt.Sprintf("/domains/%s/records/%s/destroy", domainId, recordId), url.Values{}, &StatusResponse{})
+	return act(fmt.Sprintf("/domains/%s/records/%s/destroy", domainId, recordId), url.Values{}, &StatusResponse{})
 }
 
 func GetKeys() ([]*Key, error) {
@@ -391,7 +447,7 @@ func AddKey(name, key string) (*Key, error) {
 	q := url.Values{}
 	q.Set("name", name)
 	q.Set("ssh_pub_key", key)
-	err := get(fmt.Sprintf("/ssh_keys/new/"), q, r)
+	err := act(fmt.Sprintf("/ssh_keys/new/"), q, r)
 	return r.Key, err
 }
 
@@ -399,12 +455,12 @@ func UpdateKey(id, key string) (*Key, error) {
 	r := &KeyResponse{}
 	q := url.Values{}
 	q.Set("ssh_pub_key", key)
-	err := get(fmt.Sprintf("/ssh_keys/%s/edit/", id), q, r)
+	err := act(fmt.Sprintf("/ssh_keys/%s/edit/", id), q, r)
 	return r.Key, err
 }
 
 func DeleteKey(id string) error {
-	return get(fmt.Sprintf("/ssh_keys/%s/destroy/", id), url.Values{}, &StatusResponse{})
+	return act(fmt.Sprintf("/ssh_keys/%s/destroy/", id), url.Values{}, &StatusResponse{})
 }
 
 func GetImages() ([]*Image, error) {
@@ -423,12 +479,12 @@ func TransferImage(imageId, regionId string) (*EventId, error) {
 	r := &EventIdResponse{}
 	q := url.Values{}
 	q.Set("region_id", regionId)
-	err := get(fmt.Sprintf("/images/%s/transfer/", imageId), q, r)
+	err := act(fmt.Sprintf("/images/%s/transfer/", imageId), q, r)
 	return r.EventId, err
 }
 
 func DestroyImage(id string) error {
-	return get(fmt.Sprintf("/images/%s/destroy/", id), url.Values{}, &StatusResponse{})
+	return act(fmt.Sprintf("/images/%s/destroy/", id), url.Values{}, &StatusResponse{})
 }
 
 func GetRegions() ([]*Region, error) {
@@ -449,27 +505,151 @@ func GetEvent(id string) (*Event, error) {
 	return r.Event, err
 }
 
+// Requests answered with 429 Too Many Requests are retried up to Retries
+// times, waiting as long as the Retry-After header asks, up to
+// MaxRetryDelay, or else RetryDelay and then twice as long each time. A
+// 502, 503 or 504 is retried the same way, but only for requests that
+// change nothing, since the first may have been carried out.
+var (
+	Retries       = 2
+	RetryDelay    = time.Second
+	MaxRetryDelay = 30 * time.Second
+)
+
+// get makes a request that only reads. The API takes GET for changes too,
+// so those go through act instead, which does not retry server errors.
 func get(path string, query url.Values, response Response) error {
-	u, err := url.Parse("https://api.digitalocean.com" + path)
-	if err != nil {
-		return err
-	}
-	query.Set("client_id", ClientId)
-	query.Set("api_key", ApiKey)
-	u.RawQuery = query.Encode()
-	r, err := http.Get(u.String())
+	return call(path, query, response, true)
+}
+
+// act makes a request that changes something, such as creating a droplet.
+func act(path string, query url.Values, response Response) error {
+	return call(path, query, response, false)
+}
+
+func call(path string, query url.Values, response Response, idempotent bool) error {
+	body, code, err := request("GET", path, query, idempotent)
 	if err != nil {
 		return err
 	}
-	defer r.Body.Close()
-	dec := json.NewDecoder(r.Body)
-	err = dec.Decode(response)
+	err = json.Unmarshal(body, response)
 	if err != nil {
+		if code != http.StatusOK {
+			return &APIError{code, "ERROR", http.StatusText(code)}
+		}
 		return err
 	}
 	status := response.GetStatus()
 	if status != "OK" {
-		return errors.New(fmt.Sprintf("%s: %s", status, response.GetMessage()))
+		return &APIError{code, status, response.GetMessage()}
 	}
 	return nil
 }
+
+// Raw makes a request to path, which may carry a query of its own, with
+// params and the credentials added, and returns the body. It fails in the
+// same ways as the typed calls, but accepts any body that does not report
+// an error. Server errors are only retried if the caller says the request
+// is idempotent.
+func Raw(method, path string, params url.Values, idempotent bool) ([]byte, error) {
+	body, code, err := request(method, path, params, idempotent)
+	if err != nil {
+		return nil, err
+	}
+	var status StatusResponse
+	if json.Unmarshal(body, &status) == nil && status.Status != "" && status.Status != "OK" {
+		return nil, &APIError{code, status.Status, status.Message}
+	}
+	if code != http.StatusOK {
+		message := status.Message
+		if message == "" {
+			message = http.StatusText(code)
+		}
+		return nil, &APIError{code, "ERROR", message}
+	}
+	return body, nil
+}
+
+// request is where every call goes: it adds the credentials, retries and
+// reads the whole body.
+func request(method, path string, query url.Values, idempotent bool) ([]byte, int, error) {
+	if _, err := ResolveApiKey(); err != nil {
+		return nil, 0, err
+	}
+	if ClientId == "" || ApiKey == "" {
+		return nil, 0, ErrNoCredentials
+	}
+	u, err := url.Parse(strings.TrimSuffix(BaseURL, "/") + path)
+	if err != nil {
+		return nil, 0, err
+	}
+	q := u.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("client_id", ClientId)
+	q.Set("api_key", ApiKey)
+	u.RawQuery = q.Encode()
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(Context, method, u.String(), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		r, err := Client.Do(req)
+		if err != nil {
+			// The error repeats the URL, credentials and all.
+			if uerr, ok := err.(*url.Error); ok {
+				uerr.URL = RedactURL(uerr.URL)
+			}
+			return nil, 0, err
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+		if !retryable(r.StatusCode, idempotent) || attempt == Retries {
+			return body, r.StatusCode, nil
+		}
+		delay, ok := retryAfter(r.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			delay = RetryDelay << uint(attempt)
+		}
+		if delay > MaxRetryDelay {
+			delay = MaxRetryDelay
+		}
+		select {
+		case <-time.After(delay):
+		case <-Context.Done():
+			return nil, 0, Context.Err()
+		}
+	}
+}
+
+func retryable(code int, idempotent bool) bool {
+	switch code {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// retryAfter returns the wait a Retry-After header asks for, given either
+// in seconds or as a date.
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
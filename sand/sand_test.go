@@ -0,0 +1,108 @@
+package sand
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// withServer points sand at a test server running handler with test
+// credentials, and returns a function that puts everything back.
+func withServer(handler http.HandlerFunc) func() {
+	server := httptest.NewServer(handler)
+	clientId, apiKey, baseURL := ClientId, ApiKey, BaseURL
+	retryDelay, maxRetryDelay := RetryDelay, MaxRetryDelay
+	ClientId, ApiKey, BaseURL = "id", "0123456789abcdef", server.URL
+	RetryDelay, MaxRetryDelay = time.Millisecond, 10*time.Millisecond
+	return func() {
+		server.Close()
+		ClientId, ApiKey, BaseURL = clientId, apiKey, baseURL
+		RetryDelay, MaxRetryDelay = retryDelay, maxRetryDelay
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	tests := []struct {
+		idempotent bool
+		status     int
+		attempts   int
+	}{
+		{true, http.StatusServiceUnavailable, Retries + 1},
+		{true, http.StatusBadGateway, Retries + 1},
+		{false, http.StatusServiceUnavailable, 1},
+		{false, http.StatusGatewayTimeout, 1},
+		{false, http.StatusTooManyRequests, Retries + 1},
+		{true, http.StatusInternalServerError, 1},
+		{true, http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		restore := withServer(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(tt.status)
+		})
+		_, code, err := request("GET", "/droplets/", nil, tt.idempotent)
+		restore()
+		if err != nil {
+			t.Errorf("idempotent %v, %d: %v", tt.idempotent, tt.status, err)
+			continue
+		}
+		if code != tt.status || attempts != tt.attempts {
+			t.Errorf("idempotent %v, %d: got %d after %d attempts, want %d attempts", tt.idempotent, tt.status, code, attempts, tt.attempts)
+		}
+	}
+}
+
+func TestActionsNotRetried(t *testing.T) {
+	attempts := 0
+	defer withServer(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusGatewayTimeout)
+	})()
+	DestroyDroplet("1", false)
+	if attempts != 1 {
+		t.Errorf("destroy was sent %d times after a 504, want once", attempts)
+	}
+	attempts = 0
+	GetDroplets()
+	if attempts != Retries+1 {
+		t.Errorf("list was sent %d times after a 504, want %d", attempts, Retries+1)
+	}
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	defer withServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})()
+	start := time.Now()
+	request("GET", "/droplets/", nil, true)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %s, waiting on Retry-After past MaxRetryDelay", elapsed)
+	}
+}
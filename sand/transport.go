@@ -0,0 +1,52 @@
+package sand
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// TransportOptions configures the transport made by NewTransport.
+type TransportOptions struct {
+	// Proxy is the URL of the proxy to use. If empty, the proxy comes from
+	// the HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string
+	// CABundle is a PEM file of certificates to trust as well as the
+	// system's.
+	CABundle string
+	// InsecureSkipVerify turns off TLS certificate verification.
+	InsecureSkipVerify bool
+}
+
+// NewTransport returns a transport for Client set up as o says.
+func NewTransport(o TransportOptions) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if o.Proxy != "" {
+		u, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %v", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if o.CABundle != "" || o.InsecureSkipVerify {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	}
+	if o.CABundle != "" {
+		pem, err := ioutil.ReadFile(o.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("caBundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("caBundle: no certificates in %s", o.CABundle)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	return t, nil
+}
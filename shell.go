@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/whub/faucet/cmd"
+	"github.com/whub/faucet/fancy"
+	"github.com/whub/faucet/sand"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const historySize = 500
+
+var errLineCancelled = errors.New("line cancelled")
+
+func shell(ctx *cmd.Context) error {
+	if warmReferenceData {
+		return errors.New("already in a shell")
+	}
+	warmReferenceData = true
+	defer func() { warmReferenceData = false }()
+
+	global := ctx.GlobalArgs()
+	history := loadHistory()
+	var readLine func() (string, error)
+	if fancy.IsTerminal(os.Stdin) && fancy.IsTerminal(os.Stdout) {
+		report.Infof("faucet shell: type help for commands, exit or ctrl-d to leave")
+		e := &lineEditor{
+			prompt:   fancy.Sprint(fancy.Current.Name, root.Name+"> "),
+			history:  history,
+			complete: completeLine,
+			in:       bufio.NewReader(os.Stdin),
+		}
+		readLine = e.readLine
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		readLine = func() (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err == errLineCancelled {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+		appendHistory(line)
+		words, err := cmd.Split(line)
+		if err != nil {
+			report.Error(err)
+			continue
+		}
+		if words[0] == root.Name {
+			words = words[1:]
+		}
+		args := append(append([]string{root.Name}, global...), words...)
+		printError(runInShell(args))
+	}
+}
+
+// runInShell dispatches args so that ctrl-c cancels the command and returns
+// to the prompt, rather than ending the shell.
+func runInShell(args []string) error {
+	c, cancel := context.WithCancel(context.Background())
+	sand.Context = c
+	stop := onInterrupt(cancel)
+	err := root.Dispatch(args, 1)
+	stop()
+	cancel()
+	sand.Context = context.Background()
+	if errors.Is(err, context.Canceled) {
+		report.Prompt("\n")
+		return errInterrupted
+	}
+	return err
+}
+
+// completeLine returns the candidates for the last word of line, without
+// their descriptions, and that word.
+func completeLine(line string) ([]string, string) {
+	words, err := cmd.Split(line)
+	if err != nil {
+		return nil, ""
+	}
+	if len(words) == 0 || strings.HasSuffix(line, " ") {
+		words = append(words, "")
+	}
+	if words[0] == root.Name && len(words) > 1 {
+		words = words[1:]
+	}
+	completing = true
+	defer func() { completing = false }()
+	var candidates []string
+	for _, c := range root.Complete(words) {
+		candidates = append(candidates, strings.SplitN(c, "\t", 2)[0])
+	}
+	return candidates, words[len(words)-1]
+}
+
+func historyPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "faucet", "history"), nil
+}
+
+func loadHistory() []string {
+	path, err := historyPath()
+	if err != nil {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) > historySize {
+		lines = lines[len(lines)-historySize:]
+	}
+	return lines
+}
+
+// appendHistory adds line to the history file. Once the file holds twice
+// historySize lines it is cut back to the last historySize, so it stays
+// small without being rewritten for every line.
+func appendHistory(line string) {
+	path, err := historyPath()
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0700) != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+	b, err := ioutil.ReadFile(path)
+	if err != nil || strings.Count(string(b), "\n") < 2*historySize {
+		return
+	}
+	writeFilePrivate(path, []byte(strings.Join(loadHistory(), "\n")+"\n"))
+}
+
+// stty runs stty against the terminal on stdin.
+func stty(args ...string) (string, error) {
+	c := exec.Command("stty", args...)
+	c.Stdin = os.Stdin
+	out, err := c.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// rawMode switches the terminal to reading single keys without echo, and
+// returns a function that puts it back.
+func rawMode() (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return nil, err
+	}
+	return onExit(func() { stty(state) }), nil
+}
+
+// lineEditor reads a line from the terminal with cursor movement, history
+// and tab completion.
+type lineEditor struct {
+	prompt   string
+	history  []string
+	complete func(line string) ([]string, string)
+	in       *bufio.Reader
+	buf      []rune
+	pos      int
+}
+
+func (e *lineEditor) readLine() (string, error) {
+	restore, err := rawMode()
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	e.buf, e.pos = nil, 0
+	hist, current := len(e.history), ""
+	e.redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Println()
+			line := string(e.buf)
+			if strings.TrimSpace(line) != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != line) {
+				e.history = append(e.history, line)
+			}
+			return line, nil
+		case 3: // ctrl-c
+			fmt.Println("^C")
+			return "", errLineCancelled
+		case 4: // ctrl-d
+			if len(e.buf) == 0 {
+				fmt.Println()
+				return "", io.EOF
+			}
+			e.delete(e.pos)
+		case 127, 8: // backspace
+			if e.pos > 0 {
+				e.pos--
+				e.delete(e.pos)
+			}
+		case 1: // ctrl-a
+			e.pos = 0
+		case 5: // ctrl-e
+			e.pos = len(e.buf)
+		case 11: // ctrl-k
+			e.buf = e.buf[:e.pos]
+		case 21: // ctrl-u
+			e.buf, e.pos = e.buf[e.pos:], 0
+		case 23: // ctrl-w
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.buf, e.pos = append(e.buf[:start], e.buf[e.pos:]...), start
+		case '\t':
+			e.tab()
+		case 27: // escape sequence
+			if b, _ := e.in.ReadByte(); b != '[' {
+				break
+			}
+			code, _ := e.in.ReadByte()
+			switch code {
+			case 'A', 'B':
+				if hist == len(e.history) {
+					current = string(e.buf)
+				}
+				if code == 'A' && hist > 0 {
+					hist--
+				} else if code == 'B' && hist < len(e.history) {
+					hist++
+				}
+				if hist == len(e.history) {
+					e.buf = []rune(current)
+				} else {
+					e.buf = []rune(e.history[hist])
+				}
+				e.pos = len(e.buf)
+			case 'C':
+				if e.pos < len(e.buf) {
+					e.pos++
+				}
+			case 'D':
+				if e.pos > 0 {
+					e.pos--
+				}
+			case 'H':
+				e.pos = 0
+			case 'F':
+				e.pos = len(e.buf)
+			case '3':
+				if b, _ := e.in.ReadByte(); b == '~' && e.pos < len(e.buf) {
+					e.delete(e.pos)
+				}
+			}
+		default:
+			if r >= ' ' {
+				e.buf = append(e.buf[:e.pos], append([]rune{r}, e.buf[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.redraw()
+	}
+}
+
+func (e *lineEditor) delete(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+func (e *lineEditor) insert(s string) {
+	rs := []rune(s)
+	e.buf = append(e.buf[:e.pos], append(rs, e.buf[e.pos:]...)...)
+	e.pos += len(rs)
+}
+
+// tab completes the word before the cursor as far as the candidates agree,
+// and lists them when that adds nothing.
+func (e *lineEditor) tab() {
+	candidates, word := e.complete(string(e.buf[:e.pos]))
+	if len(candidates) == 0 {
+		return
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(candidates) == 1 {
+		e.insert(strings.TrimPrefix(prefix, word) + " ")
+		return
+	}
+	if len(prefix) > len(word) {
+		e.insert(strings.TrimPrefix(prefix, word))
+		return
+	}
+	fmt.Printf("\r\n%s\n", strings.Join(candidates, "  "))
+}
+
+func (e *lineEditor) redraw() {
+	fmt.Printf("\r\x1b[K%s%s", e.prompt, string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Printf("\x1b[%dD", back)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHistoryTrimmed(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	path, err := historyPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := 3*historySize + 10
+	for i := 1; i <= last; i++ {
+		appendHistory(strconv.Itoa(i))
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(b), "\n"); n >= 2*historySize {
+			t.Fatalf("after %d lines the history file holds %d", i, n)
+		}
+	}
+	history := loadHistory()
+	if len(history) != historySize || history[len(history)-1] != strconv.Itoa(last) {
+		t.Errorf("history = %d lines ending %q, want the last %d", len(history), history[len(history)-1], historySize)
+	}
+}